@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"log/slog"
+
+	williamfindlaycom "github.com/willfindlay/williamfindlaycom"
+	"github.com/willfindlay/williamfindlaycom/internal/config"
+	"github.com/willfindlay/williamfindlaycom/internal/server"
+)
+
+// runBuild exports the whole site as static files for deployment to a
+// static host or CDN.
+func runBuild(args []string) int {
+	flags := flag.NewFlagSet("build", flag.ContinueOnError)
+	out := flags.String("out", "dist", "output directory")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		slog.Error("config", "err", err)
+		return 1
+	}
+
+	srv, err := server.New(cfg, williamfindlaycom.Embedded)
+	if err != nil {
+		slog.Error("init", "err", err)
+		return 1
+	}
+
+	if err := srv.Build(*out); err != nil {
+		slog.Error("build", "err", err)
+		return 1
+	}
+	return 0
+}
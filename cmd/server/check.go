@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/willfindlay/williamfindlaycom/internal/content"
+)
+
+// runCheck validates a content directory and reports every problem found,
+// exiting non-zero if any are errors so content CI can gate on it.
+func runCheck(args []string) int {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "print diagnostics as JSON")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: server check [-json] [content-dir]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	dir := os.Getenv("CONTENT_DIR")
+	switch flags.NArg() {
+	case 0:
+		if dir == "" {
+			dir = "."
+		}
+	case 1:
+		dir = flags.Arg(0)
+	default:
+		flags.Usage()
+		return 2
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil || !info.IsDir() {
+		fmt.Fprintf(os.Stderr, "%s is not a directory\n", dir)
+		return 2
+	}
+
+	diags := content.Check(dir)
+	var errs, warnings int
+	for _, d := range diags {
+		if d.Severity == content.SeverityError {
+			errs++
+		} else {
+			warnings++
+		}
+	}
+
+	if *asJSON {
+		out := struct {
+			Diagnostics []content.Diagnostic `json:"diagnostics"`
+			Errors      int                  `json:"errors"`
+			Warnings    int                  `json:"warnings"`
+		}{diags, errs, warnings}
+		if out.Diagnostics == nil {
+			out.Diagnostics = []content.Diagnostic{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(out); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	} else {
+		for _, d := range diags {
+			fmt.Println(d)
+		}
+		fmt.Fprintf(os.Stderr, "%d error(s), %d warning(s)\n", errs, warnings)
+	}
+
+	if errs > 0 {
+		return 1
+	}
+	return 0
+}
@@ -13,6 +13,17 @@ import (
 func main() {
 	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, nil)))
 
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "preview":
+			os.Exit(runPreview(os.Args[2:]))
+		case "build":
+			os.Exit(runBuild(os.Args[2:]))
+		case "check":
+			os.Exit(runCheck(os.Args[2:]))
+		}
+	}
+
 	slog.Info("starting",
 		"version", version.Version,
 		"commit", version.Commit,
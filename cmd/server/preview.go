@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/willfindlay/williamfindlaycom/internal/config"
+	"github.com/willfindlay/williamfindlaycom/internal/handler"
+)
+
+// runPreview prints the secret preview link for each slug on the command
+// line, so unpublished posts can be shared with reviewers.
+func runPreview(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: server preview <slug>...")
+		return 2
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "config:", err)
+		return 1
+	}
+	if cfg.PreviewSecret == "" {
+		fmt.Fprintln(os.Stderr, "PREVIEW_SECRET is not set")
+		return 1
+	}
+
+	for _, slug := range args {
+		fmt.Println(handler.PreviewURL(cfg.SiteURL, cfg.PreviewSecret, slug))
+	}
+	return 0
+}
@@ -31,22 +31,49 @@ type GiscusConfig struct {
 
 type Config struct {
 	Port              string
-	ContentRepoURL    string
+	ContentRepoURL    string // empty to serve ContentDir without git
 	ContentRepoBranch string
 	ContentDir        string
+	ContentRepoRef    string // tag, commit SHA or tag glob; empty tracks ContentRepoBranch
+	ContentHistory    int    // loaded content snapshots kept for rollback
+	ImageCacheDir     string // resized content images are cached here
 	SyncInterval      time.Duration
 	GitAuthToken      string
+	GitSSHKey         string // PEM private key for SSH repo URLs
+	GitSSHPassphrase  string
+	GitKnownHosts     string // known_hosts path; empty uses the default files
+	GitSSHInsecure    bool   // skip host key checks when GitKnownHosts is empty
+	SigningKeysFile   string // if set, content commits must be signed by a key in this file
+	WebhookSecret     string
+	AdminToken        string
 	SiteTitle         string
 	SiteURL           string
 	DevMode           bool
+	AssetsDir         string // templates and static files are read from here in dev mode
+	PreviewSecret     string
 	Particles         ParticleConfig
 	Giscus            GiscusConfig
 }
 
 func Load() (*Config, error) {
+	// Without a repo URL, an existing CONTENT_DIR is served as-is.
 	repoURL := os.Getenv("CONTENT_REPO_URL")
-	if repoURL == "" {
-		return nil, fmt.Errorf("CONTENT_REPO_URL is required")
+	if repoURL == "" && os.Getenv("CONTENT_DIR") == "" {
+		return nil, fmt.Errorf("CONTENT_REPO_URL or CONTENT_DIR is required")
+	}
+
+	// The SSH key can be given inline or, to keep it out of the
+	// environment, as a path to a mounted secret.
+	sshKey := os.Getenv("GIT_SSH_KEY")
+	if path := os.Getenv("GIT_SSH_KEY_FILE"); path != "" {
+		if sshKey != "" {
+			return nil, fmt.Errorf("GIT_SSH_KEY and GIT_SSH_KEY_FILE are mutually exclusive")
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading GIT_SSH_KEY_FILE: %w", err)
+		}
+		sshKey = string(b)
 	}
 
 	syncInterval := 5 * time.Minute
@@ -63,11 +90,23 @@ func Load() (*Config, error) {
 		ContentRepoURL:    repoURL,
 		ContentRepoBranch: envOr("CONTENT_REPO_BRANCH", "main"),
 		ContentDir:        envOr("CONTENT_DIR", "/data/content"),
+		ContentRepoRef:    os.Getenv("CONTENT_REPO_REF"),
+		ContentHistory:    clampInt(envOrInt("CONTENT_HISTORY", 5), 1, 100),
+		ImageCacheDir:     envOr("IMAGE_CACHE_DIR", "/data/image-cache"),
 		SyncInterval:      syncInterval,
 		GitAuthToken:      os.Getenv("GIT_AUTH_TOKEN"),
+		GitSSHKey:         sshKey,
+		GitSSHPassphrase:  os.Getenv("GIT_SSH_KEY_PASSPHRASE"),
+		GitKnownHosts:     os.Getenv("GIT_SSH_KNOWN_HOSTS"),
+		GitSSHInsecure:    envBool("GIT_SSH_INSECURE"),
+		SigningKeysFile:   os.Getenv("CONTENT_SIGNING_KEYS_FILE"),
+		WebhookSecret:     os.Getenv("WEBHOOK_SECRET"),
+		AdminToken:        os.Getenv("ADMIN_TOKEN"),
 		SiteTitle:         envOr("SITE_TITLE", "William Findlay"),
 		SiteURL:           envOr("SITE_URL", "https://williamfindlay.com"),
 		DevMode:           os.Getenv("DEV_MODE") == "true",
+		AssetsDir:         envOr("ASSETS_DIR", "."),
+		PreviewSecret:     os.Getenv("PREVIEW_SECRET"),
 		Particles: ParticleConfig{
 			Count:           clampInt(envOrInt("PARTICLE_COUNT", 120), 1, 500),
 			Speed:           clampFloat(envOrFloat("PARTICLE_SPEED", 0.3), 0.01, 10),
@@ -117,6 +156,19 @@ func envOrInt(key string, fallback int) int {
 	return n
 }
 
+func envBool(key string) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return false
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		slog.Warn("invalid env var, using default", "key", key, "value", v, "default", false)
+		return false
+	}
+	return b
+}
+
 func envOrFloat(key string, fallback float64) float64 {
 	v := os.Getenv(key)
 	if v == "" {
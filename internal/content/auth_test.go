@@ -0,0 +1,243 @@
+package content
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"golang.org/x/crypto/ssh"
+)
+
+func newSSHKey(t *testing.T) (ssh.Signer, []byte) {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer, pem.EncodeToMemory(block)
+}
+
+func TestSyncConfig_Auth(t *testing.T) {
+	_, key := newSSHKey(t)
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(knownHosts, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SSH_KNOWN_HOSTS", knownHosts)
+
+	tests := []struct {
+		name     string
+		cfg      SyncConfig
+		wantUser string // SSH user, or "" for no SSH auth
+		wantHTTP bool
+	}{
+		{"https with token", SyncConfig{RepoURL: "https://example.com/c.git", AuthToken: "tok"}, "", true},
+		{"https anonymous", SyncConfig{RepoURL: "https://example.com/c.git"}, "", false},
+		{"scp-style ssh", SyncConfig{RepoURL: "git@example.com:me/c.git", SSHKey: key}, "git", false},
+		{"ssh URL with user", SyncConfig{RepoURL: "ssh://deploy@example.com/c.git", SSHKey: key}, "deploy", false},
+		{"ssh URL without user", SyncConfig{RepoURL: "ssh://example.com/c.git", SSHKey: key}, "git", false},
+		{"ssh ignores token", SyncConfig{RepoURL: "git@example.com:me/c.git", AuthToken: "tok"}, "", false},
+		{"local path", SyncConfig{RepoURL: "/srv/content", AuthToken: "tok"}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth, err := tt.cfg.auth()
+			if err != nil {
+				t.Fatalf("auth: %v", err)
+			}
+			switch a := auth.(type) {
+			case nil:
+				if tt.wantUser != "" || tt.wantHTTP {
+					t.Errorf("expected credentials, got none")
+				}
+			case *githttp.BasicAuth:
+				if !tt.wantHTTP || a.Password != "tok" {
+					t.Errorf("unexpected basic auth %+v", a)
+				}
+			case *gitssh.PublicKeys:
+				if a.User != tt.wantUser {
+					t.Errorf("expected SSH user %q, got %q", tt.wantUser, a.User)
+				}
+			default:
+				t.Errorf("unexpected auth method %T", auth)
+			}
+		})
+	}
+
+	if _, err := (SyncConfig{RepoURL: "git@example.com:c.git", SSHKey: []byte("not a key")}).auth(); err == nil {
+		t.Error("expected an error for an invalid SSH key")
+	}
+
+	// Without known hosts to check against, SSH auth fails rather than
+	// trusting any host key.
+	t.Setenv("SSH_KNOWN_HOSTS", filepath.Join(t.TempDir(), "missing"))
+	sshCfg := SyncConfig{RepoURL: "git@example.com:c.git", SSHKey: key}
+	if _, err := sshCfg.auth(); err == nil {
+		t.Error("expected an error without known hosts")
+	}
+	sshCfg.InsecureIgnoreHostKey = true
+	if _, err := sshCfg.auth(); err != nil {
+		t.Errorf("expected insecure mode to skip known hosts, got %v", err)
+	}
+}
+
+// serveGitSSH runs a minimal SSH server that only accepts clientKey and
+// serves git-upload-pack for any path. It returns the server's address.
+func serveGitSSH(t *testing.T, hostKey ssh.Signer, clientKey ssh.PublicKey) string {
+	t.Helper()
+	cfg := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) != string(clientKey.Marshal()) {
+				return nil, fmt.Errorf("unknown key")
+			}
+			return nil, nil
+		},
+	}
+	cfg.AddHostKey(hostKey)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() }) //nolint:errcheck
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveGitSSHConn(conn, cfg)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func serveGitSSHConn(conn net.Conn, cfg *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, cfg)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for nc := range chans {
+		ch, reqs, err := nc.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer ch.Close() //nolint:errcheck
+			for req := range reqs {
+				var exec struct{ Command string }
+				if req.Type != "exec" || ssh.Unmarshal(req.Payload, &exec) != nil {
+					req.Reply(false, nil) //nolint:errcheck
+					continue
+				}
+				req.Reply(true, nil) //nolint:errcheck
+				status := runUploadPack(ch, exec.Command)
+				ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status})) //nolint:errcheck
+				return
+			}
+		}()
+	}
+}
+
+func runUploadPack(ch ssh.Channel, command string) uint32 {
+	path, ok := strings.CutPrefix(command, "git-upload-pack ")
+	if !ok {
+		return 1
+	}
+	cmd := exec.Command("git-upload-pack", strings.Trim(path, "'"))
+	cmd.Stdin = ch
+	cmd.Stdout = ch
+	cmd.Stderr = io.Discard
+	if err := cmd.Run(); err != nil {
+		return 1
+	}
+	return 0
+}
+
+func TestSyncer_GitSSH(t *testing.T) {
+	if _, err := exec.LookPath("git-upload-pack"); err != nil {
+		t.Skip("git-upload-pack not installed")
+	}
+
+	remoteDir, _ := newRemote(t)
+	hostKey, _ := newSSHKey(t)
+	clientKey, clientPEM := newSSHKey(t)
+	addr := serveGitSSH(t, hostKey, clientKey.PublicKey())
+
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	host, port, _ := net.SplitHostPort(addr)
+	line := fmt.Sprintf("[%s]:%s %s", host, port, ssh.MarshalAuthorizedKey(hostKey.PublicKey()))
+	if err := os.WriteFile(knownHosts, []byte(line), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	otherHostKey, _ := newSSHKey(t)
+	wrongHosts := filepath.Join(t.TempDir(), "known_hosts")
+	line = fmt.Sprintf("[%s]:%s %s", host, port, ssh.MarshalAuthorizedKey(otherHostKey.PublicKey()))
+	if err := os.WriteFile(wrongHosts, []byte(line), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, otherClientPEM := newSSHKey(t)
+
+	tests := []struct {
+		name         string
+		key          []byte
+		knownHosts   string
+		defaultHosts string // SSH_KNOWN_HOSTS, the default known_hosts files
+		insecure     bool
+		wantErr      bool
+	}{
+		{"deploy key with known hosts", clientPEM, knownHosts, wrongHosts, false, false},
+		{"default known hosts", clientPEM, "", knownHosts, false, false},
+		{"unknown host key", clientPEM, wrongHosts, knownHosts, false, true},
+		{"unknown host key in default known hosts", clientPEM, "", wrongHosts, false, true},
+		{"insecure without host check", clientPEM, "", wrongHosts, true, false},
+		{"known hosts over insecure", clientPEM, wrongHosts, knownHosts, true, true},
+		{"wrong deploy key", otherClientPEM, knownHosts, knownHosts, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SSH_KNOWN_HOSTS", tt.defaultHosts)
+			store := NewAtomicStore()
+			err := NewSyncer(SyncConfig{
+				RepoURL:               fmt.Sprintf("ssh://git@%s%s", addr, remoteDir),
+				Branch:                "main",
+				Dir:                   filepath.Join(t.TempDir(), "content"),
+				SSHKey:                tt.key,
+				KnownHostsFile:        tt.knownHosts,
+				InsecureIgnoreHostKey: tt.insecure,
+			}, store).Sync()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected sync to fail")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Sync: %v", err)
+			}
+			if got := postTitles(t, store); len(got) != 1 || got[0] != "First" {
+				t.Errorf("expected the remote's post, got %v", got)
+			}
+		})
+	}
+}
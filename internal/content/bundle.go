@@ -0,0 +1,270 @@
+package content
+
+import (
+	"crypto/sha256"
+	"errors"
+	"html/template"
+	"image"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+// bundleIndex is the markdown file of a page bundle: a directory holding a
+// post or project together with the images and files it links to.
+const bundleIndex = "index.md"
+
+// markdownDoc is a post or project source in a section directory.
+type markdownDoc struct {
+	slug   string
+	name   string // slash path relative to the section dir
+	bundle bool
+}
+
+// markdownDocs lists the documents in a section directory: flat *.md files
+// and page bundles, which are directories containing an index.md.
+func markdownDocs(dir string) ([]markdownDoc, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []markdownDoc
+	for _, e := range entries {
+		switch {
+		case e.IsDir():
+			if _, err := os.Stat(filepath.Join(dir, e.Name(), bundleIndex)); err == nil {
+				docs = append(docs, markdownDoc{slug: e.Name(), name: e.Name() + "/" + bundleIndex, bundle: true})
+			}
+		case strings.HasSuffix(e.Name(), ".md"):
+			docs = append(docs, markdownDoc{slug: strings.TrimSuffix(e.Name(), ".md"), name: e.Name()})
+		}
+	}
+	return docs, nil
+}
+
+// Asset is a file of a page bundle. Only its metadata is kept in memory;
+// the file is served from disk.
+type Asset struct {
+	Path    string // on disk
+	Size    int64
+	ModTime time.Time
+	Hash    [sha256.Size]byte
+
+	// Width and Height are the dimensions of a resizable image, and zero
+	// for other files.
+	Width, Height int
+}
+
+// errAssetChanged is returned by Asset.Open when the file on disk is no
+// longer the one that was loaded, such as after rolling back content.
+var errAssetChanged = errors.New("asset changed on disk since it was loaded")
+
+// Open opens the asset's file, checking it is still the one that was
+// loaded. Files whose size or modification time changed are hashed again.
+func (a Asset) Open() (*os.File, error) {
+	f, err := os.Open(a.Path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err == nil && (info.Size() != a.Size || !info.ModTime().Equal(a.ModTime)) {
+		var sum [sha256.Size]byte
+		if sum, err = hashReader(f); err == nil && sum != a.Hash {
+			err = errAssetChanged
+		}
+		if err == nil {
+			_, err = f.Seek(0, io.SeekStart)
+		}
+	}
+	if err != nil {
+		f.Close() //nolint:errcheck
+		return nil, err
+	}
+	return f, nil
+}
+
+// ReadFile returns the contents of the asset's file, failing as Open does
+// if it has changed since it was loaded.
+func (a Asset) ReadFile() ([]byte, error) {
+	data, err := os.ReadFile(a.Path)
+	if err != nil {
+		return nil, err
+	}
+	if sha256.Sum256(data) != a.Hash {
+		return nil, errAssetChanged
+	}
+	return data, nil
+}
+
+// readAssets lists every file in a page bundle except its index.md, keyed
+// by slash path relative to the bundle. Dotfiles are skipped. Files are
+// hashed, unless prev, by path on disk, has them with the same size and
+// modification time.
+func readAssets(dir string, prev map[string]Asset) (map[string]Asset, error) {
+	assets := make(map[string]Asset)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(d.Name(), ".") && p != dir {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == bundleIndex {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if a, ok := prev[p]; ok && a.Size == info.Size() && a.ModTime.Equal(info.ModTime()) {
+			assets[rel] = a
+			return nil
+		}
+		a, err := statAsset(p, rel, info)
+		if err != nil {
+			return err
+		}
+		assets[rel] = a
+		return nil
+	})
+	return assets, err
+}
+
+// statAsset hashes the file at p and, if it is a resizable image, reads
+// its dimensions.
+func statAsset(p, name string, info fs.FileInfo) (Asset, error) {
+	a := Asset{Path: p, Size: info.Size(), ModTime: info.ModTime()}
+	f, err := os.Open(p)
+	if err != nil {
+		return a, err
+	}
+	defer f.Close() //nolint:errcheck // read-only
+	if a.Hash, err = hashReader(f); err != nil {
+		return a, err
+	}
+	if isResizable(name) {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return a, err
+		}
+		if cfg, _, err := image.DecodeConfig(f); err == nil {
+			a.Width, a.Height = cfg.Width, cfg.Height
+		}
+	}
+	return a, nil
+}
+
+func hashReader(r io.Reader) ([sha256.Size]byte, error) {
+	var sum [sha256.Size]byte
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return sum, err
+	}
+	h.Sum(sum[:0])
+	return sum, nil
+}
+
+// sourceHash hashes a document and its assets, so editing any file in a
+// bundle causes it to be re-rendered.
+func sourceHash(data []byte, assets map[string]Asset) [sha256.Size]byte {
+	if len(assets) == 0 {
+		return sha256.Sum256(data)
+	}
+
+	names := make([]string, 0, len(assets))
+	for name := range assets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	h.Write(data)
+	for _, name := range names {
+		sum := assets[name].Hash
+		h.Write([]byte{0})
+		h.Write([]byte(name))
+		h.Write([]byte{0})
+		h.Write(sum[:])
+	}
+	var sum [sha256.Size]byte
+	h.Sum(sum[:0])
+	return sum
+}
+
+// resolveAssetURL resolves a link or image destination in a bundle against
+// the bundle's URL. Absolute URLs, absolute paths and fragment-only links
+// are returned unchanged.
+func resolveAssetURL(base, dest string) string {
+	if base == "" || dest == "" || strings.HasPrefix(dest, "/") || strings.HasPrefix(dest, "#") {
+		return dest
+	}
+	u, err := url.Parse(dest)
+	if err != nil || u.Scheme != "" || u.Host != "" {
+		return dest
+	}
+	b, err := url.Parse(base)
+	if err != nil {
+		return dest
+	}
+	return b.ResolveReference(u).String()
+}
+
+// assetBaseKey holds the URL relative links in the document being rendered
+// resolve against. It is only set for page bundles.
+var assetBaseKey = parser.NewContextKey()
+
+// assetLinkTransformer rewrites relative link and image destinations in a
+// page bundle to the URLs its assets are served from.
+type assetLinkTransformer struct{}
+
+func (assetLinkTransformer) Transform(doc *ast.Document, _ text.Reader, pc parser.Context) {
+	base, _ := pc.Get(assetBaseKey).(string)
+	if base == "" {
+		return
+	}
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) { //nolint:errcheck // the walker never fails
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.Link:
+			n.Destination = []byte(resolveAssetURL(base, string(n.Destination)))
+		case *ast.Image:
+			n.Destination = []byte(resolveAssetURL(base, string(n.Destination)))
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+// RenderWithBase renders the post again with its bundle's relative links
+// and images resolved against base instead of the post's URL, for serving
+// its assets from elsewhere. A post that isn't a bundle has no such links,
+// so its content is returned as is.
+func (p *BlogPost) RenderWithBase(base string) (template.HTML, error) {
+	if p.source == nil {
+		return p.Content, nil
+	}
+	var meta BlogPost
+	content, _, err := renderMarkdown(markdownSource{slug: p.Slug, data: p.source, base: base, assets: p.Assets}, &meta)
+	return content, err
+}
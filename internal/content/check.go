@@ -0,0 +1,347 @@
+package content
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+	"gopkg.in/yaml.v3"
+)
+
+// Check validates the content in dir and returns every problem it finds,
+// sorted by file and line, where LoadFromDir would stop at the first one.
+// Files the loader rejects are errors, with every problem the loader finds
+// in them. On top of that, Check checks the links, images and tags of the
+// content that loads.
+func Check(dir string) []Diagnostic {
+	c := &checker{dir: dir}
+	cs, err := Reload(dir, nil)
+	if err != nil {
+		c.add("", 0, SeverityError, "%v", err)
+		return c.diags
+	}
+	c.store = cs
+	for _, e := range cs.LoadErrors {
+		c.diags = append(c.diags, e.Diagnostics()...)
+	}
+
+	c.checkDocs()
+	c.checkRedirects()
+	c.checkTags()
+	c.checkLinks()
+
+	sort.SliceStable(c.diags, func(i, j int) bool {
+		a, b := c.diags[i], c.diags[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+	return c.diags
+}
+
+type checker struct {
+	dir   string
+	store *ContentStore
+	diags []Diagnostic
+
+	docs      map[string]map[string]bool // section -> slug -> published
+	tags      []tagUse
+	links     []linkUse
+	redirects map[string]bool // from paths
+}
+
+type tagUse struct {
+	tag  string
+	file string
+	line int
+}
+
+type linkUse struct {
+	dest string
+	file string
+	line int
+}
+
+func (c *checker) add(file string, line int, sev Severity, format string, args ...any) {
+	c.diags = append(c.diags, Diagnostic{
+		File:     filepath.ToSlash(file),
+		Line:     line,
+		Severity: sev,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// checkDocs checks the body of each post and project that loaded and
+// collects its tags and links.
+func (c *checker) checkDocs() {
+	c.docs = map[string]map[string]bool{"blog": {}, "projects": {}}
+	for _, file := range slices.Sorted(maps.Keys(c.store.rendered)) {
+		var slug string
+		var tags []string
+		var assets map[string]Asset
+		var published bool
+		switch item := c.store.rendered[file].item.(type) {
+		case BlogPost:
+			slug, tags, assets = item.Slug, item.Tags, item.Assets
+			published = c.store.PostsBySlug[slug] != nil
+		case Project:
+			slug, tags, assets = item.Slug, item.Tags, item.Assets
+			published = c.store.ProjectsBySlug[slug] != nil
+		default:
+			continue
+		}
+		section, _, _ := strings.Cut(file, "/")
+		c.docs[section][slug] = published
+
+		data, err := os.ReadFile(filepath.Join(c.dir, filepath.FromSlash(file)))
+		if err != nil {
+			c.add(file, 0, SeverityError, "reading file: %v", err)
+			continue
+		}
+		c.collectTags(file, data, tags)
+		base := ""
+		if path.Base(file) == bundleIndex {
+			base = "/" + path.Dir(file) + "/"
+		}
+		c.checkBody(file, data, base, assets)
+	}
+}
+
+// collectTags records the tags of a document with the lines they are on.
+func (c *checker) collectTags(file string, data []byte, tags []string) {
+	lines := map[string]int{}
+	if fm, ok := splitFrontmatter(data); ok {
+		var node yaml.Node
+		if yaml.Unmarshal(fm, &node) == nil {
+			if key := mappingValue(&node, "tags"); key != nil {
+				for _, t := range key.Content {
+					lines[t.Value] = frontmatterLine - 1 + t.Line
+				}
+			}
+		}
+	}
+	for _, tag := range tags {
+		c.tags = append(c.tags, tagUse{tag: tag, file: file, line: lines[tag]})
+	}
+}
+
+// checkBody collects the links in a document and checks its images. In a
+// page bundle, where base is the bundle's URL, relative links must name
+// one of its assets, and must be markdown rather than raw HTML.
+func (c *checker) checkBody(file string, data []byte, base string, assets map[string]Asset) {
+	doc := md.Parser().Parse(text.NewReader(data))
+	checkAsset := func(n ast.Node, dest string) {
+		name, ok := strings.CutPrefix(resolveAssetURL(base, dest), base)
+		if base == "" || !ok {
+			return
+		}
+		if u, err := url.Parse(name); err == nil {
+			name = u.Path
+		}
+		if name == "" {
+			return
+		}
+		if _, ok := assets[name]; !ok {
+			c.add(file, nodeLine(n, data), SeverityError, "link to missing bundle file %s", dest)
+		}
+	}
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) { //nolint:errcheck // the walker never fails
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.Link:
+			c.links = append(c.links, linkUse{
+				dest: resolveAssetURL(base, string(n.Destination)),
+				file: file,
+				line: nodeLine(n, data),
+			})
+			checkAsset(n, string(n.Destination))
+		case *ast.Image:
+			checkAsset(n, string(n.Destination))
+			if strings.TrimSpace(nodeText(n, data)) == "" {
+				c.add(file, nodeLine(n, data), SeverityError, "image %s has no alt text", n.Destination)
+			}
+		case *ast.RawHTML:
+			c.checkRawHTML(file, data, base, n.Segments)
+		case *ast.HTMLBlock:
+			c.checkRawHTML(file, data, base, n.Lines())
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+// rawURLRE matches the src and href attributes of raw HTML.
+var rawURLRE = regexp.MustCompile(`(?i)\b(?:src|href)\s*=\s*["']([^"']*)["']`)
+
+// checkRawHTML reports relative URLs in raw HTML in a page bundle. Unlike
+// markdown links and images, they aren't resolved against the bundle's
+// URL, so they point outside it.
+func (c *checker) checkRawHTML(file string, data []byte, base string, segs *text.Segments) {
+	if base == "" {
+		return
+	}
+	for i := range segs.Len() {
+		seg := segs.At(i)
+		html := seg.Value(data)
+		for _, m := range rawURLRE.FindAllSubmatchIndex(html, -1) {
+			dest := string(html[m[2]:m[3]])
+			if resolveAssetURL(base, dest) == dest {
+				continue
+			}
+			line := 1 + bytes.Count(data[:seg.Start+m[0]], []byte("\n"))
+			c.add(file, line, SeverityError, "relative URL %s in raw HTML isn't resolved against the bundle; use markdown", dest)
+		}
+	}
+}
+
+// checkRedirects collects the targets of redirects as links to check. The
+// loader drops _redirects.yaml entirely if any redirect is bad, so it is
+// parsed again here for the redirects that are there.
+func (c *checker) checkRedirects() {
+	c.redirects = map[string]bool{}
+	data, err := os.ReadFile(filepath.Join(c.dir, redirectsFile))
+	if err != nil {
+		return
+	}
+	redirects, _ := parseRedirects(data)
+	for _, r := range redirects {
+		c.redirects[r.From] = true
+		if r.To != "" {
+			c.links = append(c.links, linkUse{dest: r.To, file: redirectsFile, line: r.line})
+		}
+	}
+}
+
+// checkTags flags tags missing from tags.yaml, if there is one, and tags
+// that differ from another tag only in case or punctuation, which would
+// otherwise split posts across two tag pages. Tags are first mapped
+// through the taxonomy, as the loader does.
+func (c *checker) checkTags() {
+	for i, u := range c.tags {
+		if slug, ok := c.store.tagAliases[foldTag(u.tag)]; ok {
+			c.tags[i].tag = slug
+		} else if len(c.store.Tags) > 0 {
+			c.add(u.file, u.line, SeverityWarning, "tag %q is not in %s", u.tag, tagsFile)
+		}
+	}
+
+	counts := map[string]int{}
+	spellings := map[string][]string{}
+	for _, u := range c.tags {
+		if counts[u.tag] == 0 {
+			key := foldTag(u.tag)
+			spellings[key] = append(spellings[key], u.tag)
+		}
+		counts[u.tag]++
+	}
+
+	for _, u := range c.tags {
+		variants := spellings[foldTag(u.tag)]
+		if len(variants) < 2 {
+			continue
+		}
+		// The most used spelling is taken to be the intended one.
+		best := variants[0]
+		for _, v := range variants[1:] {
+			if counts[v] > counts[best] || (counts[v] == counts[best] && v < best) {
+				best = v
+			}
+		}
+		if u.tag != best {
+			c.add(u.file, u.line, SeverityWarning, "tag %q looks like a variant of %q", u.tag, best)
+		}
+	}
+}
+
+func foldTag(tag string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, tag)
+}
+
+// checkLinks reports internal links to posts and projects that don't
+// exist or aren't published.
+func (c *checker) checkLinks() {
+	for _, l := range c.links {
+		u, err := url.Parse(l.dest)
+		if err != nil {
+			c.add(l.file, l.line, SeverityError, "invalid link %q: %v", l.dest, err)
+			continue
+		}
+		if u.Scheme != "" || u.Host != "" || !strings.HasPrefix(u.Path, "/") {
+			continue
+		}
+		if c.redirects[u.Path] {
+			continue
+		}
+
+		section, slug, ok := strings.Cut(strings.Trim(u.Path, "/"), "/")
+		docs, known := c.docs[section]
+		if !ok || !known || strings.Contains(slug, "/") {
+			continue
+		}
+		published, exists := docs[slug]
+		switch {
+		case !exists:
+			c.add(l.file, l.line, SeverityError, "link to nonexistent %s", u.Path)
+		case !published:
+			c.add(l.file, l.line, SeverityWarning, "link to unpublished %s", u.Path)
+		}
+	}
+}
+
+// nodeLine finds the source line of n, from its own text if it has any or
+// else from the block containing it.
+func nodeLine(n ast.Node, src []byte) int {
+	offset := -1
+	ast.Walk(n, func(c ast.Node, entering bool) (ast.WalkStatus, error) { //nolint:errcheck // the walker never fails
+		if t, ok := c.(*ast.Text); ok && entering {
+			offset = t.Segment.Start
+			return ast.WalkStop, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	for p := n; offset < 0 && p != nil; p = p.Parent() {
+		if p.Type() == ast.TypeBlock && p.Lines().Len() > 0 {
+			offset = p.Lines().At(0).Start
+		}
+	}
+	if offset < 0 {
+		return 0
+	}
+	return 1 + bytes.Count(src[:offset], []byte("\n"))
+}
+
+// nodeText concatenates the text inside n, such as an image's alt text.
+func nodeText(n ast.Node, src []byte) string {
+	var b strings.Builder
+	ast.Walk(n, func(c ast.Node, entering bool) (ast.WalkStatus, error) { //nolint:errcheck // the walker never fails
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch c := c.(type) {
+		case *ast.Text:
+			b.Write(c.Segment.Value(src))
+		case *ast.String:
+			b.Write(c.Value)
+		}
+		return ast.WalkContinue, nil
+	})
+	return b.String()
+}
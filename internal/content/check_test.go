@@ -0,0 +1,212 @@
+package content
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeContent(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	writeContent(t, dir, map[string]string{
+		"blog/good.md": `---
+title: Good
+date: 2024-01-01
+tags: [go]
+---
+
+See [the other post](/blog/links) and [my project](/projects/tool).
+`,
+		"blog/links.md": `---
+title: Links
+date: 2024-01-02
+tags: [go]
+---
+
+A [missing post](/blog/nope), a [draft](/blog/draft#intro)
+and a [moved post](/old).
+
+![](/static/diagram.png)
+`,
+		"blog/draft.md": `---
+title: Draft
+date: 2024-01-03
+draft: true
+tags: [Go]
+---
+`,
+		"blog/shout.md": `---
+title: Quiet
+date: 2024-01-04
+---
+`,
+		"blog/SHOUT.md": `---
+title: Shouting
+date: 2024-01-04
+---
+`,
+		"blog/untitled.md": `---
+description: no title or date
+---
+`,
+		"blog/bad-date.md": `---
+title: Bad date
+date: someday
+---
+`,
+		"blog/bad-yaml.md": `---
+title: [unclosed
+---
+`,
+		"blog/bundle/index.md": `---
+title: Bundle
+date: 2024-01-05
+---
+
+![Photo](photo.jpg) and ![Chart](chart.png)
+[Back](../good) and [gone](../nope)
+
+<figure><img src="./figure.jpg" alt="Figure"></figure>
+
+An inline <img src="/static/logo.png" alt="Logo"> is fine.
+`,
+		"blog/bundle/photo.jpg": "jpg",
+		"projects/tool.md": `---
+title: Tool
+date: 2024-01-01
+---
+`,
+		"_redirects.yaml": `- from: /old
+  to: /blog/good
+- from: /old
+  to: /blog/good
+- from: /gone
+  to: /projects/missing
+  code: 200
+`,
+	})
+
+	type want struct {
+		file string
+		line int
+		sev  Severity
+		msg  string
+	}
+	wants := []want{
+		{"blog/shout.md", 0, SeverityError, `slug "shout" clashes with blog/SHOUT.md`},
+		{"blog/bad-date.md", 3, SeverityError, `invalid date "someday"`},
+		{"blog/bad-yaml.md", 0, SeverityError, "invalid YAML"},
+		{"blog/bundle/index.md", 6, SeverityError, "link to missing bundle file chart.png"},
+		{"blog/bundle/index.md", 7, SeverityError, "link to nonexistent /blog/nope"},
+		{"blog/bundle/index.md", 9, SeverityError, "relative URL ./figure.jpg in raw HTML"},
+		{"blog/draft.md", 5, SeverityWarning, `tag "Go" looks like a variant of "go"`},
+		{"blog/links.md", 7, SeverityError, "link to nonexistent /blog/nope"},
+		{"blog/links.md", 7, SeverityWarning, "link to unpublished /blog/draft"},
+		{"blog/links.md", 10, SeverityError, "image /static/diagram.png has no alt text"},
+		{"blog/untitled.md", 2, SeverityError, "missing title"},
+		{"blog/untitled.md", 2, SeverityError, "missing date"},
+		{"_redirects.yaml", 3, SeverityError, `duplicate 'from' path "/old"`},
+		{"_redirects.yaml", 5, SeverityError, "code 200 not in 300-399 range"},
+		{"_redirects.yaml", 5, SeverityError, "link to nonexistent /projects/missing"},
+	}
+
+	diags := Check(dir)
+	for _, w := range wants {
+		found := false
+		for _, d := range diags {
+			if d.File == w.file && (w.line == 0 || d.Line == w.line) && d.Severity == w.sev && strings.Contains(d.Message, w.msg) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("missing diagnostic %s:%d: %s: %s", w.file, w.line, w.sev, w.msg)
+		}
+	}
+
+	for _, d := range diags {
+		if d.File == "blog/good.md" || d.File == "projects/tool.md" {
+			t.Errorf("unexpected diagnostic for valid content: %s", d)
+		}
+		if strings.Contains(d.Message, "photo.jpg") || strings.Contains(d.Message, "/blog/good") {
+			t.Errorf("unexpected diagnostic for a valid bundle link: %s", d)
+		}
+		if strings.Contains(d.Message, "logo.png") {
+			t.Errorf("unexpected diagnostic for an absolute URL in raw HTML: %s", d)
+		}
+		if strings.Contains(d.Message, "/old") && strings.Contains(d.Message, "link") {
+			t.Errorf("link through a redirect should be allowed: %s", d)
+		}
+	}
+	if t.Failed() {
+		for _, d := range diags {
+			t.Log(d)
+		}
+	}
+}
+
+func TestCheck_Clean(t *testing.T) {
+	dir := t.TempDir()
+	writeContent(t, dir, map[string]string{
+		"blog/post.md": "---\ntitle: Post\ndate: 2024-01-01\n---\n\n![A diagram](/static/d.png)\n",
+	})
+	if diags := Check(dir); len(diags) != 0 {
+		t.Errorf("expected no diagnostics, got %v", diags)
+	}
+}
+
+func TestCheck_ReservedSlugs(t *testing.T) {
+	for _, slug := range []string{"series", "tags"} {
+		dir := t.TempDir()
+		writeContent(t, dir, map[string]string{
+			"blog/" + slug + ".md": "---\ntitle: Post\ndate: 2024-01-01\n---\n",
+		})
+		diags := Check(dir)
+		if len(diags) != 1 || diags[0].Severity != SeverityError || !strings.Contains(diags[0].Message, "reserved") {
+			t.Errorf("%s: expected a reserved slug error, got %v", slug, diags)
+		}
+	}
+}
+
+func TestCheck_TagTaxonomy(t *testing.T) {
+	dir := t.TempDir()
+	writeContent(t, dir, map[string]string{
+		"tags.yaml":   "ebpf:\n  name: eBPF\n  aliases: [bpf]\n",
+		"blog/one.md": "---\ntitle: One\ndate: 2024-01-01\ntags: [ebpf]\n---\n",
+		"blog/two.md": "---\ntitle: Two\ndate: 2024-01-02\ntags:\n  - BPF\n  - rust\n---\n",
+	})
+
+	diags := Check(dir)
+	if len(diags) != 1 {
+		t.Fatalf("expected 1 diagnostic, got %v", diags)
+	}
+	if d := diags[0]; d.File != "blog/two.md" || d.Line != 6 || d.Severity != SeverityWarning || d.Message != `tag "rust" is not in tags.yaml` {
+		t.Errorf("unexpected diagnostic: %s", d)
+	}
+
+	writeContent(t, dir, map[string]string{"tags.yaml": "ebpf:\n  aliases: [bpf]\nbpf:\n  name: BPF\n"})
+	diags = Check(dir)
+	found := false
+	for _, d := range diags {
+		if d.File == "tags.yaml" && d.Severity == SeverityError && strings.Contains(d.Message, "already used") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected an error for a clashing alias, got %v", diags)
+	}
+}
@@ -0,0 +1,99 @@
+package content
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic is a problem found by Check.
+type Diagnostic struct {
+	File     string   `json:"file,omitempty"` // relative to the content dir
+	Line     int      `json:"line,omitempty"` // 1-based; 0 if unknown
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	loc := d.File
+	if loc == "" {
+		loc = "."
+	}
+	if d.Line > 0 {
+		loc += ":" + strconv.Itoa(d.Line)
+	}
+	return fmt.Sprintf("%s: %s: %s", loc, d.Severity, d.Message)
+}
+
+// Diagnostics returns the problems that made the file fail to load, one
+// for each error joined in Err, with the line each one is on if known.
+func (e LoadError) Diagnostics() []Diagnostic {
+	var diags []Diagnostic
+	var add func(err error)
+	add = func(err error) {
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, err := range joined.Unwrap() {
+				add(err)
+			}
+			return
+		}
+		d := Diagnostic{File: e.File, Severity: SeverityError, Message: err.Error()}
+		var le *lineError
+		if errors.As(err, &le) {
+			d.Line, d.Message = le.line, le.err.Error()
+		}
+		diags = append(diags, d)
+	}
+	add(e.Err)
+	return diags
+}
+
+// lineError is a problem on a line of a content file.
+type lineError struct {
+	line int // 1-based
+	err  error
+}
+
+func (e *lineError) Error() string { return fmt.Sprintf("line %d: %v", e.line, e.err) }
+func (e *lineError) Unwrap() error { return e.err }
+
+// errorAt returns an error on the given line of a file.
+func errorAt(line int, format string, args ...any) error {
+	return &lineError{line: line, err: fmt.Errorf(format, args...)}
+}
+
+var yamlLineRE = regexp.MustCompile(`line (\d+): (.*)`)
+
+// yamlErrors splits a YAML error into an error for each of its messages,
+// on their lines offset by the line the YAML document starts after.
+func yamlErrors(offset int, err error) error {
+	var msgs []string
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		msgs = typeErr.Errors
+	} else {
+		msgs = []string{strings.TrimPrefix(err.Error(), "yaml: ")}
+	}
+
+	errs := make([]error, len(msgs))
+	for i, msg := range msgs {
+		if m := yamlLineRE.FindStringSubmatch(msg); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			errs[i] = errorAt(offset+n, "invalid YAML: %s", m[2])
+		} else {
+			errs[i] = fmt.Errorf("invalid YAML: %s", msg)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,136 @@
+package content
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// fetchedRef is where the commit being synced to is fetched into.
+const fetchedRef = plumbing.ReferenceName("refs/content/target")
+
+// target describes what is being synced to, for logs.
+func (cfg SyncConfig) target() string {
+	if cfg.Ref != "" {
+		return cfg.Ref
+	}
+	return cfg.Branch
+}
+
+// Tracks reports whether a push to ref can change what cfg syncs to.
+func (cfg SyncConfig) Tracks(ref string) bool {
+	switch {
+	case cfg.Ref == "":
+		return ref == refName(cfg.Branch).String()
+	case isCommitHash(cfg.Ref):
+		return false
+	case isTagGlob(cfg.Ref):
+		tag, ok := strings.CutPrefix(ref, "refs/tags/")
+		if !ok {
+			return false
+		}
+		matched, _ := path.Match(cfg.Ref, tag)
+		return matched
+	default:
+		return ref == "refs/tags/"+cfg.Ref
+	}
+}
+
+// resolveSource returns the remote ref, or commit hash, to fetch.
+func resolveSource(cfg SyncConfig) (string, error) {
+	switch {
+	case cfg.Ref == "":
+		return refName(cfg.Branch).String(), nil
+	case isCommitHash(cfg.Ref):
+		return cfg.Ref, nil
+	case isTagGlob(cfg.Ref):
+		tag, err := latestTag(cfg)
+		if err != nil {
+			return "", err
+		}
+		return "refs/tags/" + tag, nil
+	default:
+		return "refs/tags/" + cfg.Ref, nil
+	}
+}
+
+func isCommitHash(ref string) bool {
+	return plumbing.IsHash(ref)
+}
+
+func isTagGlob(ref string) bool {
+	return strings.ContainsAny(ref, "*?[")
+}
+
+// latestTag lists the remote's tags and returns the last one matching
+// cfg.Ref in natural order, so "release-10" comes after "release-9".
+func latestTag(cfg SyncConfig) (string, error) {
+	if _, err := path.Match(cfg.Ref, ""); err != nil {
+		return "", fmt.Errorf("invalid tag pattern %q: %w", cfg.Ref, err)
+	}
+
+	auth, err := cfg.auth()
+	if err != nil {
+		return "", err
+	}
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: git.DefaultRemoteName,
+		URLs: []string{cfg.RepoURL},
+	})
+	refs, err := remote.List(&git.ListOptions{Auth: auth})
+	if err != nil {
+		return "", fmt.Errorf("listing tags: %w", err)
+	}
+
+	var tags []string
+	for _, ref := range refs {
+		if !ref.Name().IsTag() {
+			continue
+		}
+		tag := ref.Name().Short()
+		if matched, _ := path.Match(cfg.Ref, tag); matched {
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) == 0 {
+		return "", fmt.Errorf("no tags match %q", cfg.Ref)
+	}
+	return slices.MaxFunc(tags, compareNatural), nil
+}
+
+// compareNatural compares strings treating runs of digits as numbers.
+func compareNatural(a, b string) int {
+	for a != "" && b != "" {
+		da, db := leadingDigits(a), leadingDigits(b)
+		if da != "" && db != "" {
+			na, nb := strings.TrimLeft(da, "0"), strings.TrimLeft(db, "0")
+			if c := len(na) - len(nb); c != 0 {
+				return c
+			}
+			if c := strings.Compare(na, nb); c != 0 {
+				return c
+			}
+			a, b = a[len(da):], b[len(db):]
+			continue
+		}
+		if a[0] != b[0] {
+			return int(a[0]) - int(b[0])
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) - len(b)
+}
+
+func leadingDigits(s string) string {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i]
+}
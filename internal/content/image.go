@@ -0,0 +1,289 @@
+package content
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"golang.org/x/image/draw"
+)
+
+// imageWidths are the widths, in pixels, that responsive variants of bundle
+// images are generated at. Widths at or above an image's own are skipped.
+var imageWidths = []int{480, 960, 1600}
+
+// imageSizes is the sizes attribute of content images, which are never
+// wider than the prose column.
+const imageSizes = "(max-width: 768px) 100vw, 720px"
+
+// maxImagePixels bounds the images that get variants, so a huge image in
+// the content repo can't exhaust memory when decoded.
+const maxImagePixels = 50_000_000
+
+// isResizable reports whether the asset name is an image format variants
+// are generated for. GIFs are left alone since they may be animated.
+func isResizable(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".png", ".jpg", ".jpeg":
+		return true
+	}
+	return false
+}
+
+// variantName returns the asset name of name's variant at width, e.g.
+// "diagram.480w.png" for "diagram.png".
+func variantName(name string, width int) string {
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + strconv.Itoa(width) + "w" + ext
+}
+
+// parseVariant splits a variant asset name into the name of its original
+// and its width.
+func parseVariant(name string) (string, int, bool) {
+	ext := path.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	dot := strings.LastIndexByte(stem, '.')
+	if dot < 0 || !strings.HasSuffix(stem, "w") {
+		return "", 0, false
+	}
+	width, err := strconv.Atoi(stem[dot+1 : len(stem)-1])
+	if err != nil {
+		return "", 0, false
+	}
+	return stem[:dot] + ext, width, true
+}
+
+// variantWidths returns the variant widths of an image asset.
+func variantWidths(a Asset) []int {
+	if a.Width*a.Height > maxImagePixels {
+		return nil
+	}
+	var widths []int
+	for _, w := range imageWidths {
+		if w < a.Width {
+			widths = append(widths, w)
+		}
+	}
+	return widths
+}
+
+// ImageVariants returns the asset names of every responsive variant of the
+// images in a page bundle's assets, sorted.
+func ImageVariants(assets map[string]Asset) []string {
+	var names []string
+	for name, a := range assets {
+		for _, w := range variantWidths(a) {
+			names = append(names, variantName(name, w))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ImageCache generates images, such as responsive variants, and caches
+// them on disk. A nil *ImageCache generates images without caching them.
+type ImageCache struct {
+	dir string
+	mu  sync.Mutex // serializes generating images, which is CPU and memory heavy
+}
+
+// NewImageCache returns a cache storing variants in dir. If dir can't be
+// created, variants are still generated but not cached.
+func NewImageCache(dir string) *ImageCache {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		slog.Warn("image cache disabled", "dir", dir, "err", err)
+		dir = ""
+	}
+	return &ImageCache{dir: dir}
+}
+
+// Variant returns the named variant of an image in assets, generating it
+// if it isn't cached. It reports false if name isn't a variant of one of
+// the images, or if the image has since been changed or removed on disk.
+func (c *ImageCache) Variant(assets map[string]Asset, name string) ([]byte, bool, error) {
+	orig, width, ok := parseVariant(name)
+	if !ok {
+		return nil, false, nil
+	}
+	a, ok := assets[orig]
+	if !ok || !slices.Contains(variantWidths(a), width) {
+		return nil, false, nil
+	}
+
+	key := fmt.Sprintf("%s-%d%s", hex.EncodeToString(a.Hash[:]), width, strings.ToLower(path.Ext(orig)))
+	out, err := c.Cached(key, func() ([]byte, error) {
+		data, err := a.ReadFile()
+		if err != nil {
+			return nil, err
+		}
+		return resizeImage(data, width)
+	})
+	if errors.Is(err, errAssetChanged) || errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("resizing %s: %w", orig, err)
+	}
+	return out, true, nil
+}
+
+// Cached returns the cached file named key, calling generate to create it
+// if it isn't cached. Keys should be derived from a hash of whatever the
+// file is generated from, so stale files are never served.
+func (c *ImageCache) Cached(key string, generate func() ([]byte, error)) ([]byte, error) {
+	if c == nil {
+		return generate()
+	}
+
+	file := ""
+	if c.dir != "" {
+		file = filepath.Join(c.dir, key)
+		if out, err := os.ReadFile(file); err == nil {
+			return out, nil
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Another request may have generated it while this one waited.
+	if file != "" {
+		if out, err := os.ReadFile(file); err == nil {
+			return out, nil
+		}
+	}
+
+	out, err := generate()
+	if err != nil {
+		return nil, err
+	}
+	if file != "" {
+		if err := writeFileAtomic(file, out); err != nil {
+			slog.Warn("caching image", "file", file, "err", err)
+		}
+	}
+	return out, nil
+}
+
+// resizeImage scales a PNG or JPEG image down to width, keeping its aspect
+// ratio and format.
+func resizeImage(data []byte, width int) ([]byte, error) {
+	src, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	b := src.Bounds()
+	height := max(1, b.Dy()*width/b.Dx())
+
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Src, nil)
+
+	var buf bytes.Buffer
+	switch format {
+	case "jpeg":
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85})
+	case "png":
+		err = (&png.Encoder{CompressionLevel: png.BestCompression}).Encode(&buf, dst)
+	default:
+		err = fmt.Errorf("unsupported image format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeFileAtomic(file string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(file), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) //nolint:errcheck // gone after a successful rename
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close() //nolint:errcheck
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// assetsKey holds the assets of the page bundle being rendered.
+var assetsKey = parser.NewContextKey()
+
+// imageTransformer lazy-loads every image and, for images in a page
+// bundle, adds their dimensions and a srcset of their resized variants.
+// It runs after assetLinkTransformer, so destinations are already
+// resolved.
+type imageTransformer struct{}
+
+func (imageTransformer) Transform(doc *ast.Document, _ text.Reader, pc parser.Context) {
+	base, _ := pc.Get(assetBaseKey).(string)
+	assets, _ := pc.Get(assetsKey).(map[string]Asset)
+
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) { //nolint:errcheck // the walker never fails
+		img, ok := n.(*ast.Image)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+		setDefaultAttr(img, "loading", "lazy")
+		setDefaultAttr(img, "decoding", "async")
+
+		if base == "" {
+			return ast.WalkContinue, nil
+		}
+		u, err := url.Parse(string(img.Destination))
+		if err != nil || u.RawQuery != "" {
+			return ast.WalkContinue, nil
+		}
+		name, ok := strings.CutPrefix(u.Path, base)
+		if !ok {
+			return ast.WalkContinue, nil
+		}
+		a := assets[name]
+		if a.Width == 0 {
+			return ast.WalkContinue, nil
+		}
+		setDefaultAttr(img, "width", strconv.Itoa(a.Width))
+		setDefaultAttr(img, "height", strconv.Itoa(a.Height))
+
+		widths := variantWidths(a)
+		if len(widths) == 0 {
+			return ast.WalkContinue, nil
+		}
+		srcset := make([]string, 0, len(widths)+1)
+		for _, w := range widths {
+			v := url.URL{Path: base + variantName(name, w)}
+			srcset = append(srcset, v.EscapedPath()+" "+strconv.Itoa(w)+"w")
+		}
+		srcset = append(srcset, u.EscapedPath()+" "+strconv.Itoa(a.Width)+"w")
+		img.SetAttributeString("srcset", []byte(strings.Join(srcset, ", ")))
+		img.SetAttributeString("sizes", []byte(imageSizes))
+		return ast.WalkContinue, nil
+	})
+}
+
+func setDefaultAttr(n ast.Node, name, value string) {
+	if _, ok := n.AttributeString(name); !ok {
+		n.SetAttributeString(name, []byte(value))
+	}
+}
@@ -0,0 +1,122 @@
+package content
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestParseVariant(t *testing.T) {
+	tests := []struct {
+		name  string
+		orig  string
+		width int
+		ok    bool
+	}{
+		{"diagram.480w.png", "diagram.png", 480, true},
+		{"shots/a.b.960w.jpg", "shots/a.b.jpg", 960, true},
+		{"diagram.png", "", 0, false},
+		{"diagram.wide.png", "", 0, false},
+	}
+	for _, tt := range tests {
+		orig, width, ok := parseVariant(tt.name)
+		if orig != tt.orig || width != tt.width || ok != tt.ok {
+			t.Errorf("parseVariant(%q) = %q, %d, %v", tt.name, orig, width, ok)
+		}
+		if ok && variantName(orig, width) != tt.name {
+			t.Errorf("variantName(%q, %d) = %q", orig, width, variantName(orig, width))
+		}
+	}
+}
+
+func TestImageCache_Variant(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cache")
+	cache := NewImageCache(dir)
+	bundle := t.TempDir()
+	writeContent(t, bundle, map[string]string{
+		"big.png":   string(testPNG(t, 1200, 600)),
+		"small.png": string(testPNG(t, 300, 300)),
+	})
+	assets, err := readAssets(bundle, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, ok, err := cache.Variant(assets, "big.480w.png")
+	if err != nil || !ok {
+		t.Fatalf("Variant: %v, %v", ok, err)
+	}
+	cfg, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Width != 480 || cfg.Height != 240 {
+		t.Errorf("expected a 480x240 variant, got %dx%d", cfg.Width, cfg.Height)
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected one cached variant, got %v (%v)", files, err)
+	}
+	cached, _, err := cache.Variant(assets, "big.480w.png")
+	if err != nil || !bytes.Equal(cached, data) {
+		t.Errorf("expected the cached variant to be served, got err %v", err)
+	}
+
+	for _, name := range []string{"big.1600w.png", "big.500w.png", "small.480w.png", "missing.480w.png"} {
+		if _, ok, err := cache.Variant(assets, name); ok || err != nil {
+			t.Errorf("expected no variant %s, got %v, %v", name, ok, err)
+		}
+	}
+
+	got := ImageVariants(assets)
+	if strings.Join(got, ",") != "big.480w.png,big.960w.png" {
+		t.Errorf("unexpected variants %v", got)
+	}
+
+	// Variants aren't made from an image that changed since it was loaded.
+	writeContent(t, bundle, map[string]string{"big.png": string(testPNG(t, 1000, 600))})
+	if _, ok, err := cache.Variant(assets, "big.960w.png"); ok || err != nil {
+		t.Errorf("expected no variant of a changed image, got %v, %v", ok, err)
+	}
+}
+
+func TestLoadFromDir_ResponsiveImages(t *testing.T) {
+	dir := t.TempDir()
+	writeContent(t, dir, map[string]string{
+		"blog/post/index.md": "---\ntitle: Post\ndate: 2024-01-01\n---\n\n" +
+			"![Big](big.png)\n\n![Small](small.png)\n\n![Remote](https://example.com/x.png)\n",
+		"blog/post/big.png":   string(testPNG(t, 1200, 600)),
+		"blog/post/small.png": string(testPNG(t, 300, 200)),
+	})
+
+	store, err := LoadFromDir(dir)
+	if err != nil {
+		t.Fatalf("LoadFromDir: %v", err)
+	}
+	content := string(store.PostsBySlug["post"].Content)
+
+	for _, want := range []string{
+		`src="/blog/post/big.png" alt="Big" loading="lazy" decoding="async" width="1200" height="600" ` +
+			`srcset="/blog/post/big.480w.png 480w, /blog/post/big.960w.png 960w, /blog/post/big.png 1200w" sizes="` + imageSizes + `"`,
+		`src="/blog/post/small.png" alt="Small" loading="lazy" decoding="async" width="300" height="200">`,
+		`src="https://example.com/x.png" alt="Remote" loading="lazy" decoding="async">`,
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("expected %s in content, got:\n%s", want, content)
+		}
+	}
+}
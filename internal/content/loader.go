@@ -2,13 +2,18 @@ package content
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	htmlpkg "html"
 	"html/template"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
+	"time"
 
 	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
 	"github.com/yuin/goldmark"
@@ -16,6 +21,7 @@ import (
 	"github.com/yuin/goldmark/extension"
 	"github.com/yuin/goldmark/parser"
 	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
 	"github.com/yuin/goldmark/util"
 	"go.abhg.dev/goldmark/frontmatter"
 	"gopkg.in/yaml.v3"
@@ -36,6 +42,10 @@ var md = goldmark.New(
 	goldmark.WithParserOptions(
 		parser.WithAutoHeadingID(),
 		parser.WithAttribute(),
+		parser.WithASTTransformers(
+			util.Prioritized(assetLinkTransformer{}, 100),
+			util.Prioritized(imageTransformer{}, 200),
+		),
 	),
 	goldmark.WithRendererOptions(
 		html.WithUnsafe(),
@@ -96,83 +106,239 @@ func codeBlockWrapper(w util.BufWriter, ctx highlighting.CodeBlockContext, enter
 	}
 }
 
+// now returns the current time when deciding whether scheduled content is
+// due. Tests replace it to pin the clock.
+var now = time.Now
+
+// LoadFromDir loads all content in dir, failing on the first bad file.
 func LoadFromDir(dir string) (*ContentStore, error) {
-	store := &ContentStore{
-		PostsBySlug:    make(map[string]*BlogPost),
-		PostsByTag:     make(map[string][]*BlogPost),
-		ProjectsBySlug: make(map[string]*Project),
-		Redirects:      make(map[string]Redirect),
+	return load(&loader{dir: dir, strict: true})
+}
+
+// Reload loads all content in dir like LoadFromDir, except that a file that
+// fails to load is skipped and recorded in LoadErrors rather than failing
+// the whole load. Where prev, which may be nil, has a version of a skipped
+// item, that version is kept.
+func Reload(dir string, prev *ContentStore) (*ContentStore, error) {
+	return load(&loader{dir: dir, prev: prev})
+}
+
+// loader carries the state of a single load.
+type loader struct {
+	dir    string
+	store  *ContentStore
+	prev   *ContentStore
+	strict bool
+}
+
+// fail handles a bad file. A strict load returns err, aborting the load;
+// otherwise err is recorded and nil returned so loading carries on.
+func (l *loader) fail(file string, err error) error {
+	if l.strict {
+		return err
+	}
+	l.store.LoadErrors = append(l.store.LoadErrors, LoadError{File: file, Err: err})
+	return nil
+}
+
+func load(l *loader) (*ContentStore, error) {
+	l.store = &ContentStore{
+		PostsBySlug:       make(map[string]*BlogPost),
+		PostsByTag:        make(map[string][]*BlogPost),
+		PostsBySeries:     make(map[string][]*BlogPost),
+		Tags:              make(map[string]TagInfo),
+		UnpublishedBySlug: make(map[string]*BlogPost),
+		ProjectsBySlug:    make(map[string]*Project),
+		Redirects:         make(map[string]Redirect),
+		rendered:          make(map[string]renderedFile),
+		assetFiles:        make(map[string]Asset),
+		tagAliases:        make(map[string]string),
+	}
+
+	if err := l.loadTags(); err != nil {
+		return nil, fmt.Errorf("loading tags: %w", err)
 	}
 
-	if err := loadBlogPosts(filepath.Join(dir, "blog"), store); err != nil {
+	if err := l.loadBlogPosts(); err != nil {
 		return nil, fmt.Errorf("loading blog posts: %w", err)
 	}
 
-	if err := loadProjects(filepath.Join(dir, "projects"), store); err != nil {
+	if err := l.loadProjects(); err != nil {
 		return nil, fmt.Errorf("loading projects: %w", err)
 	}
 
-	if err := loadResume(filepath.Join(dir, "resume"), store); err != nil {
+	if err := l.loadResume(); err != nil {
 		return nil, fmt.Errorf("loading resume: %w", err)
 	}
 
-	if err := loadRedirects(dir, store); err != nil {
+	if err := l.loadRedirects(); err != nil {
 		return nil, fmt.Errorf("loading redirects: %w", err)
 	}
 
-	return store, nil
+	l.store.SiteSearch = newSiteIndex(l.store)
+	return l.store, nil
+}
+
+// reservedSlugs lists, by section, the slugs documents can't have because
+// other pages are routed under them: a bundle's assets at /blog/series/...
+// or /blog/tags/... would be shadowed by the series or tag pages.
+var reservedSlugs = map[string][]string{
+	"blog": {"series", "tags"},
+}
+
+// reservedSlug returns an error if slug is reserved in section.
+func reservedSlug(section, slug string) error {
+	if slices.Contains(reservedSlugs[section], slug) {
+		return fmt.Errorf("slug %q is reserved for the /%s/%s/ pages", slug, section, slug)
+	}
+	return nil
+}
+
+// markdownSource is a document read by loadMarkdownDir.
+type markdownSource struct {
+	slug   string
+	data   []byte
+	base   string           // URL relative links resolve against; empty unless a bundle
+	assets map[string]Asset // nil unless a bundle
 }
 
-func loadMarkdownDir[T any](dir string, decode func([]byte, string) (T, error)) ([]T, error) {
-	entries, err := os.ReadDir(dir)
+// loadMarkdownDir decodes each markdown file or page bundle in section,
+// reusing the previous load's result for documents whose contents haven't
+// changed. It also returns the slugs of documents that were skipped because
+// they failed to load.
+func loadMarkdownDir[T any](l *loader, section string, decode func(markdownSource) (T, error)) (items []T, failed []string, err error) {
+	dir := filepath.Join(l.dir, section)
+	docs, err := markdownDocs(dir)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, nil
+			return nil, nil, nil
 		}
-		return nil, err
+		return nil, nil, err
 	}
 
-	var items []T
-	for _, e := range entries {
-		if e.IsDir() || !strings.HasSuffix(e.Name(), ".md") {
+	// Slugs differing only in case clash too: they can't be checked out
+	// side by side on case-insensitive filesystems.
+	seen := make(map[string]string) // lower-cased slug -> file
+	for _, doc := range docs {
+		file := section + "/" + doc.name
+		src := markdownSource{slug: doc.slug}
+
+		folded := strings.ToLower(doc.slug)
+		if other, ok := seen[folded]; ok {
+			if err := l.fail(file, fmt.Errorf("slug %q clashes with %s", doc.slug, other)); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		seen[folded] = file
+		if err := reservedSlug(section, doc.slug); err != nil {
+			if err := l.fail(file, err); err != nil {
+				return nil, nil, err
+			}
 			continue
 		}
 
-		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		src.data, err = os.ReadFile(filepath.Join(dir, filepath.FromSlash(doc.name)))
+		if err == nil && doc.bundle {
+			src.base = "/" + section + "/" + doc.slug + "/"
+			var prev map[string]Asset
+			if l.prev != nil {
+				prev = l.prev.assetFiles
+			}
+			src.assets, err = readAssets(filepath.Join(dir, doc.slug), prev)
+			for _, a := range src.assets {
+				l.store.assetFiles[a.Path] = a
+			}
+		}
 		if err != nil {
-			return nil, fmt.Errorf("reading %s: %w", e.Name(), err)
+			if err := l.fail(file, err); err != nil {
+				return nil, nil, fmt.Errorf("reading %s: %w", doc.name, err)
+			}
+			failed = append(failed, doc.slug)
+			continue
+		}
+
+		hash := sourceHash(src.data, src.assets)
+		if item, ok := reuse[T](l.prev, file, hash); ok {
+			l.store.rendered[file] = renderedFile{hash: hash, item: item}
+			items = append(items, item)
+			continue
 		}
 
-		slug := strings.TrimSuffix(e.Name(), ".md")
-		item, err := decode(data, slug)
+		var item T
+		if err = checkFrontmatter(src.data, new(T)); err == nil {
+			item, err = decode(src)
+		}
 		if err != nil {
-			return nil, fmt.Errorf("parsing %s: %w", e.Name(), err)
+			if err := l.fail(file, err); err != nil {
+				return nil, nil, fmt.Errorf("parsing %s: %w", doc.name, err)
+			}
+			failed = append(failed, doc.slug)
+			continue
 		}
 
+		l.store.rendered[file] = renderedFile{hash: hash, item: item}
 		items = append(items, item)
 	}
 
-	return items, nil
+	return items, failed, nil
+}
+
+// reuse returns the item prev decoded from file, if the file's contents
+// still hash the same.
+func reuse[T any](prev *ContentStore, file string, hash [sha256.Size]byte) (T, bool) {
+	var zero T
+	if prev == nil {
+		return zero, false
+	}
+	r, ok := prev.rendered[file]
+	if !ok || r.hash != hash {
+		return zero, false
+	}
+	item, ok := r.item.(T)
+	return item, ok
 }
 
-func loadBlogPosts(dir string, store *ContentStore) error {
-	posts, err := loadMarkdownDir(dir, func(data []byte, slug string) (BlogPost, error) {
+func (l *loader) loadBlogPosts() error {
+	store := l.store
+	posts, failed, err := loadMarkdownDir(l, "blog", func(src markdownSource) (BlogPost, error) {
 		var post BlogPost
-		rendered, err := renderMarkdown(data, &post)
+		rendered, toc, err := renderMarkdown(src, &post)
 		if err != nil {
 			return post, err
 		}
-		post.Slug = slug
+		post.Slug = src.slug
 		post.Content = rendered
-		post.PlainText = extractBody(data)
+		post.TOC = showTOC(toc, post.ShowTOC)
+		post.Assets = src.assets
+		if src.base != "" {
+			post.source = src.data
+		}
+		post.PlainText = extractBody(src.data)
 		post.ReadingTime = readingTime(stripCodeBlocks(post.PlainText))
 		return post, nil
 	})
 	if err != nil {
 		return err
 	}
+	if l.prev != nil {
+		for _, slug := range failed {
+			if p, ok := l.prev.PostsBySlug[slug]; ok {
+				posts = append(posts, *p)
+			} else if p, ok := l.prev.UnpublishedBySlug[slug]; ok {
+				posts = append(posts, *p)
+			}
+		}
+	}
 
-	store.Posts = posts
+	for i := range posts {
+		posts[i].Tags = store.normalizeTags(posts[i].Tags)
+	}
+
+	var unpublished []BlogPost
+	store.Posts, unpublished = splitPublished(posts, store, func(p BlogPost) (bool, time.Time) {
+		return p.Draft, p.Date
+	})
 	sort.Slice(store.Posts, func(i, j int) bool {
 		return store.Posts[i].Date.After(store.Posts[j].Date)
 	})
@@ -184,26 +350,46 @@ func loadBlogPosts(dir string, store *ContentStore) error {
 			store.PostsByTag[tag] = append(store.PostsByTag[tag], p)
 		}
 	}
+	store.indexSeries()
+	store.Search = newPostIndex(store.Posts, store.CanonicalTag)
+
+	for i := range unpublished {
+		p := &unpublished[i]
+		store.UnpublishedBySlug[p.Slug] = p
+	}
 
 	return nil
 }
 
-func loadProjects(dir string, store *ContentStore) error {
-	projects, err := loadMarkdownDir(dir, func(data []byte, slug string) (Project, error) {
+func (l *loader) loadProjects() error {
+	store := l.store
+	projects, failed, err := loadMarkdownDir(l, "projects", func(src markdownSource) (Project, error) {
 		var proj Project
-		rendered, err := renderMarkdown(data, &proj)
+		rendered, toc, err := renderMarkdown(src, &proj)
 		if err != nil {
 			return proj, err
 		}
-		proj.Slug = slug
+		proj.Slug = src.slug
 		proj.Content = rendered
+		proj.TOC = showTOC(toc, proj.ShowTOC)
+		proj.Assets = src.assets
+		proj.PlainText = extractBody(src.data)
 		return proj, nil
 	})
 	if err != nil {
 		return err
 	}
+	if l.prev != nil {
+		for _, slug := range failed {
+			if p, ok := l.prev.ProjectsBySlug[slug]; ok {
+				projects = append(projects, *p)
+			}
+		}
+	}
 
-	store.Projects = projects
+	store.Projects, _ = splitPublished(projects, store, func(p Project) (bool, time.Time) {
+		return p.Draft, p.Date
+	})
 	sort.Slice(store.Projects, func(i, j int) bool {
 		return store.Projects[i].Date.After(store.Projects[j].Date)
 	})
@@ -216,19 +402,42 @@ func loadProjects(dir string, store *ContentStore) error {
 	return nil
 }
 
-func loadResume(dir string, store *ContentStore) error {
-	path := filepath.Join(dir, "resume.yaml")
-	data, err := os.ReadFile(path)
+// splitPublished separates drafts and items dated in the future from live
+// ones, recording the earliest future date in store.NextPublish so the sync
+// loop can reload when it comes due.
+func splitPublished[T any](items []T, store *ContentStore, status func(T) (draft bool, date time.Time)) (published, unpublished []T) {
+	t := now()
+	for _, item := range items {
+		draft, date := status(item)
+		if draft {
+			unpublished = append(unpublished, item)
+			continue
+		}
+		if date.After(t) {
+			if store.NextPublish.IsZero() || date.Before(store.NextPublish) {
+				store.NextPublish = date
+			}
+			unpublished = append(unpublished, item)
+			continue
+		}
+		published = append(published, item)
+	}
+	return published, unpublished
+}
+
+func (l *loader) loadResume() error {
+	const file = "resume/resume.yaml"
+	data, err := os.ReadFile(filepath.Join(l.dir, file))
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil
 		}
-		return err
+		return l.failResume(file, err)
 	}
 
 	var resume Resume
 	if err := yaml.Unmarshal(data, &resume); err != nil {
-		return fmt.Errorf("parsing resume YAML: %w", err)
+		return l.failResume(file, yamlErrors(0, err))
 	}
 
 	// Render summary inline markdown.
@@ -271,45 +480,151 @@ func loadResume(dir string, store *ContentStore) error {
 		}
 	}
 
-	store.Resume = &resume
+	setResumeIDs(&resume)
+	l.store.Resume = &resume
 	return nil
 }
 
-func loadRedirects(dir string, store *ContentStore) error {
-	path := filepath.Join(dir, "_redirects.yaml")
-	data, err := os.ReadFile(path)
+// setResumeIDs gives each entry of the résumé an HTML id derived from its
+// title, so other pages can link to it. Publications are numbered instead,
+// as their citations are too long to make readable ids.
+func setResumeIDs(r *Resume) {
+	used := make(map[string]bool)
+	id := func(prefix, title string) string {
+		base := strings.TrimSuffix(prefix+"-"+SeriesSlug(title), "-")
+		id := base
+		for n := 2; used[id]; n++ {
+			id = fmt.Sprintf("%s-%d", base, n)
+		}
+		used[id] = true
+		return id
+	}
+
+	for _, section := range []struct {
+		prefix  string
+		entries []ResumeEntry
+	}{
+		{"experience", r.Experience},
+		{"education", r.Education},
+		{"research", r.Research},
+	} {
+		for i := range section.entries {
+			e := &section.entries[i]
+			e.ID = id(section.prefix, e.Title+" "+e.Organization)
+		}
+	}
+	for i := range r.Presentations {
+		r.Presentations[i].ID = id("talk", r.Presentations[i].Title)
+	}
+	n := 0
+	for i := range r.Publications {
+		sec := &r.Publications[i]
+		sec.ItemIDs = make([]string, len(sec.Items))
+		for j := range sec.Items {
+			n++
+			sec.ItemIDs[j] = id("publication", fmt.Sprint(n))
+		}
+	}
+	for i := range r.OpenSource {
+		for j := range r.OpenSource[i].Projects {
+			p := &r.OpenSource[i].Projects[j]
+			p.ID = id("oss", p.Name)
+		}
+	}
+}
+
+func (l *loader) failResume(file string, err error) error {
+	if err := l.fail(file, err); err != nil {
+		return err
+	}
+	if l.prev != nil {
+		l.store.Resume = l.prev.Resume
+	}
+	return nil
+}
+
+// redirectsFile lists redirects from old paths, such as those of renamed
+// posts.
+const redirectsFile = "_redirects.yaml"
+
+func (l *loader) loadRedirects() error {
+	data, err := os.ReadFile(filepath.Join(l.dir, redirectsFile))
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil
 		}
+		return l.failRedirects(err)
+	}
+
+	redirects, err := parseRedirects(data)
+	if err != nil {
+		return l.failRedirects(err)
+	}
+	for _, r := range redirects {
+		l.store.Redirects[r.From] = r
+	}
+	return nil
+}
+
+// failRedirects keeps all of the previous redirects if the file is bad, as
+// a partial set could send visitors somewhere unintended.
+func (l *loader) failRedirects(err error) error {
+	if err := l.fail(redirectsFile, err); err != nil {
 		return err
 	}
+	if l.prev != nil {
+		maps.Copy(l.store.Redirects, l.prev.Redirects)
+	}
+	return nil
+}
 
-	var redirects []Redirect
-	if err := yaml.Unmarshal(data, &redirects); err != nil {
-		return fmt.Errorf("parsing redirects YAML: %w", err)
+// parseRedirects parses _redirects.yaml, reporting every bad redirect.
+// Whatever redirects could be decoded are returned even if some are bad.
+func parseRedirects(data []byte) ([]Redirect, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, yamlErrors(0, err)
+	}
+	if len(node.Content) == 0 {
+		return nil, nil
+	}
+	list := node.Content[0]
+	if list.Kind != yaml.SequenceNode {
+		return nil, errorAt(list.Line, "expected a list of redirects")
 	}
 
-	for i, r := range redirects {
-		if r.From == "" {
-			return fmt.Errorf("redirect %d: empty 'from' path", i)
+	var redirects []Redirect
+	var errs []error
+	seen := make(map[string]bool)
+	for i, item := range list.Content {
+		var r Redirect
+		if err := item.Decode(&r); err != nil {
+			errs = append(errs, yamlErrors(0, err))
+			continue
 		}
+		r.line = item.Line
+
+		switch {
+		case r.From == "":
+			errs = append(errs, errorAt(item.Line, "redirect %d: empty 'from' path", i))
+		case !strings.HasPrefix(r.From, "/"):
+			errs = append(errs, errorAt(item.Line, "redirect %d: 'from' path %q must start with /", i, r.From))
+		case seen[r.From]:
+			errs = append(errs, errorAt(item.Line, "redirect %d: duplicate 'from' path %q", i, r.From))
+		}
+		seen[r.From] = true
 		if r.To == "" {
-			return fmt.Errorf("redirect %d: empty 'to' path", i)
+			errs = append(errs, errorAt(item.Line, "redirect %d: empty 'to' path", i))
 		}
 		if r.Code == 0 {
 			r.Code = 301
 		}
 		if r.Code < 300 || r.Code > 399 {
-			return fmt.Errorf("redirect %d: code %d not in 300-399 range", i, r.Code)
-		}
-		if _, exists := store.Redirects[r.From]; exists {
-			return fmt.Errorf("redirect %d: duplicate 'from' path %q", i, r.From)
+			errs = append(errs, errorAt(item.Line, "redirect %d: code %d not in 300-399 range", i, r.Code))
 		}
-		store.Redirects[r.From] = r
+		redirects = append(redirects, r)
 	}
-
-	return nil
+	return redirects, errors.Join(errs...)
 }
 
 func renderEntry(e *ResumeEntry) {
@@ -403,19 +718,102 @@ func extractBody(src []byte) string {
 	return strings.TrimSpace(s[3+end+4:])
 }
 
-func renderMarkdown(src []byte, meta any) (template.HTML, error) {
+// frontmatterLine is the line the frontmatter YAML starts on, just after
+// the opening "---".
+const frontmatterLine = 2
+
+// checkFrontmatter reports every problem with a document's frontmatter:
+// missing or invalid YAML, fields of the wrong type for meta, the
+// document's type, and a missing title or date.
+func checkFrontmatter(data []byte, meta any) error {
+	fm, ok := splitFrontmatter(bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n")))
+	if !ok {
+		return errorAt(1, "missing frontmatter")
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal(fm, &node); err != nil {
+		return yamlErrors(frontmatterLine-1, err)
+	}
+
+	var errs []error
+	// yaml.v3 reports bad timestamps without a line number, so dates are
+	// checked on their own first.
+	badDate := false
+	if v := mappingValue(&node, "date"); v != nil {
+		var date time.Time
+		if err := v.Decode(&date); err != nil {
+			errs = append(errs, errorAt(frontmatterLine-1+v.Line, "invalid date %q, want YYYY-MM-DD", v.Value))
+			badDate = true
+		}
+	}
+	var required struct {
+		Title string    `yaml:"title"`
+		Date  time.Time `yaml:"date"`
+	}
+	if node.Kind != 0 {
+		var typeErr *yaml.TypeError
+		if err := node.Decode(meta); err != nil && (errors.As(err, &typeErr) || !badDate) {
+			errs = append(errs, yamlErrors(frontmatterLine-1, err))
+		}
+		node.Decode(&required) //nolint:errcheck // errors were reported above
+	}
+	if strings.TrimSpace(required.Title) == "" {
+		errs = append(errs, errorAt(frontmatterLine, "missing title"))
+	}
+	if required.Date.IsZero() && !badDate {
+		errs = append(errs, errorAt(frontmatterLine, "missing date"))
+	}
+	return errors.Join(errs...)
+}
+
+// splitFrontmatter returns the YAML between the opening and closing "---"
+// lines.
+func splitFrontmatter(data []byte) ([]byte, bool) {
+	rest, ok := bytes.CutPrefix(data, []byte("---\n"))
+	if !ok {
+		return nil, false
+	}
+	if bytes.HasPrefix(rest, []byte("---")) {
+		return nil, true
+	}
+	end := bytes.Index(rest, []byte("\n---"))
+	if end < 0 {
+		return nil, false
+	}
+	return rest[:end+1], true
+}
+
+func mappingValue(doc *yaml.Node, key string) *yaml.Node {
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil
+	}
+	m := doc.Content[0]
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// renderMarkdown renders a document and decodes its frontmatter into meta.
+// It also returns the document's headings as a table of contents.
+func renderMarkdown(src markdownSource, meta any) (template.HTML, []TOCEntry, error) {
 	ctx := parser.NewContext()
+	ctx.Set(assetBaseKey, src.base)
+	ctx.Set(assetsKey, src.assets)
+	doc := md.Parser().Parse(text.NewReader(src.data), parser.WithContext(ctx))
 	var buf bytes.Buffer
-	if err := md.Convert(src, &buf, parser.WithContext(ctx)); err != nil {
-		return "", err
+	if err := md.Renderer().Render(&buf, src.data, doc); err != nil {
+		return "", nil, err
 	}
 
 	d := frontmatter.Get(ctx)
 	if d != nil {
 		if err := d.Decode(meta); err != nil {
-			return "", fmt.Errorf("decoding frontmatter: %w", err)
+			return "", nil, fmt.Errorf("decoding frontmatter: %w", err)
 		}
 	}
 
-	return template.HTML(buf.String()), nil
+	return template.HTML(buf.String()), buildTOC(doc, src.data), nil
 }
@@ -1,10 +1,14 @@
 package content
 
 import (
+	"errors"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoadFromDir_BlogPosts(t *testing.T) {
@@ -172,7 +176,7 @@ tags: [a, b]
 Paragraph with **bold**.
 `
 	var post BlogPost
-	rendered, err := renderMarkdown([]byte(src), &post)
+	rendered, _, err := renderMarkdown(markdownSource{data: []byte(src)}, &post)
 	if err != nil {
 		t.Fatalf("renderMarkdown: %v", err)
 	}
@@ -318,7 +322,7 @@ func main() {}
 ` + "```" + `
 `
 	var post BlogPost
-	rendered, err := renderMarkdown([]byte(src), &post)
+	rendered, _, err := renderMarkdown(markdownSource{data: []byte(src)}, &post)
 	if err != nil {
 		t.Fatalf("renderMarkdown: %v", err)
 	}
@@ -354,7 +358,7 @@ package main
 ` + "```" + `
 `
 	var post BlogPost
-	rendered, err := renderMarkdown([]byte(src), &post)
+	rendered, _, err := renderMarkdown(markdownSource{data: []byte(src)}, &post)
 	if err != nil {
 		t.Fatalf("renderMarkdown: %v", err)
 	}
@@ -387,7 +391,7 @@ plain text block
 ` + "```" + `
 `
 	var post BlogPost
-	rendered, err := renderMarkdown([]byte(src), &post)
+	rendered, _, err := renderMarkdown(markdownSource{data: []byte(src)}, &post)
 	if err != nil {
 		t.Fatalf("renderMarkdown: %v", err)
 	}
@@ -543,6 +547,10 @@ func TestLoadFromDir_RedirectsValidation(t *testing.T) {
 			name: "duplicate from",
 			yaml: "- from: /old\n  to: /a\n- from: /old\n  to: /b\n",
 		},
+		{
+			name: "relative from",
+			yaml: "- from: old\n  to: /new\n",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -609,3 +617,375 @@ func TestStripCodeBlocks(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadFromDir_DraftsAndScheduled(t *testing.T) {
+	orig := now
+	now = func() time.Time { return time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC) }
+	defer func() { now = orig }()
+
+	dir := t.TempDir()
+	blogDir := filepath.Join(dir, "blog")
+	projDir := filepath.Join(dir, "projects")
+	for _, d := range []string{blogDir, projDir} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files := map[string]string{
+		filepath.Join(blogDir, "live.md"):         "---\ntitle: Live\ndate: 2024-05-01\ntags: [go]\n---\n\nLive.\n",
+		filepath.Join(blogDir, "draft.md"):        "---\ntitle: Draft\ndate: 2024-05-02\ndraft: true\ntags: [go]\n---\n\nDraft.\n",
+		filepath.Join(blogDir, "later.md"):        "---\ntitle: Later\ndate: 2024-07-01\ntags: [go]\n---\n\nLater.\n",
+		filepath.Join(blogDir, "soon.md"):         "---\ntitle: Soon\ndate: 2024-06-02T09:00:00Z\n---\n\nSoon.\n",
+		filepath.Join(projDir, "shipped.md"):      "---\ntitle: Shipped\ndate: 2024-01-01\n---\n",
+		filepath.Join(projDir, "wip.md"):          "---\ntitle: WIP\ndate: 2024-01-01\ndraft: true\n---\n",
+		filepath.Join(projDir, "announced.md"):    "---\ntitle: Announced\ndate: 2025-01-01\n---\n",
+		filepath.Join(blogDir, "future-draft.md"): "---\ntitle: Future Draft\ndate: 2024-06-01T13:00:00Z\ndraft: true\n---\n",
+	}
+	for path, src := range files {
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store, err := LoadFromDir(dir)
+	if err != nil {
+		t.Fatalf("LoadFromDir: %v", err)
+	}
+
+	if len(store.Posts) != 1 || store.Posts[0].Slug != "live" {
+		t.Fatalf("expected only the live post, got %d posts", len(store.Posts))
+	}
+	for _, slug := range []string{"draft", "later", "soon", "future-draft"} {
+		if _, ok := store.PostsBySlug[slug]; ok {
+			t.Errorf("PostsBySlug should not contain %q", slug)
+		}
+	}
+	if n := len(store.PostsByTag["go"]); n != 1 {
+		t.Errorf("expected 1 post tagged 'go', got %d", n)
+	}
+	if len(store.UnpublishedBySlug) != 4 {
+		t.Errorf("expected 4 unpublished posts, got %d", len(store.UnpublishedBySlug))
+	}
+	if p, ok := store.UnpublishedBySlug["draft"]; !ok || p.Title != "Draft" {
+		t.Error("UnpublishedBySlug missing 'draft'")
+	}
+
+	if len(store.Projects) != 1 || store.Projects[0].Slug != "shipped" {
+		t.Fatalf("expected only the shipped project, got %d projects", len(store.Projects))
+	}
+
+	// Drafts never come due, so the next publish is the earliest scheduled item.
+	want := time.Date(2024, 6, 2, 9, 0, 0, 0, time.UTC)
+	if !store.NextPublish.Equal(want) {
+		t.Errorf("expected NextPublish %v, got %v", want, store.NextPublish)
+	}
+}
+
+func TestReload_SkipsBadFiles(t *testing.T) {
+	dir := t.TempDir()
+	post := func(title string) string {
+		return "---\ntitle: " + title + "\ndate: 2024-01-01\n---\n\nBody.\n"
+	}
+	writeContent(t, dir, map[string]string{
+		"blog/kept.md":       post("Kept"),
+		"blog/other.md":      post("Other"),
+		"projects/tool.md":   post("Tool"),
+		"resume/resume.yaml": "name: Me\n",
+		"_redirects.yaml":    "- from: /old\n  to: /blog/kept\n",
+	})
+
+	prev, err := LoadFromDir(dir)
+	if err != nil {
+		t.Fatalf("LoadFromDir: %v", err)
+	}
+
+	writeContent(t, dir, map[string]string{
+		"blog/kept.md":       "---\ntitle: [broken\n---\n",
+		"blog/other.md":      post("Other, edited"),
+		"blog/new.md":        "---\ndate: not a date\n---\n",
+		"projects/tool.md":   "---\ntitle: [broken\n---\n",
+		"resume/resume.yaml": "name: [broken\n",
+		"_redirects.yaml":    "- from: /old\n  to: /blog/kept\n  code: 200\n",
+	})
+
+	if _, err := LoadFromDir(dir); err == nil {
+		t.Fatal("expected LoadFromDir to stay strict")
+	}
+
+	store, err := Reload(dir, prev)
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+
+	var files []string
+	for _, e := range store.LoadErrors {
+		files = append(files, e.File)
+	}
+	want := []string{"blog/kept.md", "blog/new.md", "projects/tool.md", "resume/resume.yaml", "_redirects.yaml"}
+	if strings.Join(files, ",") != strings.Join(want, ",") {
+		t.Errorf("expected load errors for %v, got %v", want, files)
+	}
+
+	if p := store.PostsBySlug["kept"]; p == nil || p.Title != "Kept" {
+		t.Errorf("expected the previous version of a broken post to be kept, got %+v", p)
+	}
+	if p := store.PostsBySlug["other"]; p == nil || p.Title != "Other, edited" {
+		t.Errorf("expected good files to update, got %+v", p)
+	}
+	if _, ok := store.PostsBySlug["new"]; ok {
+		t.Error("expected a broken new post to be skipped")
+	}
+	if store.ProjectsBySlug["tool"] == nil {
+		t.Error("expected the previous version of a broken project to be kept")
+	}
+	if store.Resume == nil || store.Resume.Name != "Me" {
+		t.Error("expected the previous resume to be kept")
+	}
+	if r, ok := store.Redirects["/old"]; !ok || r.Code != 301 {
+		t.Errorf("expected the previous redirects to be kept, got %+v", store.Redirects)
+	}
+
+	// Without a previous store, broken files are just skipped.
+	store, err = Reload(dir, nil)
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if len(store.Posts) != 1 || store.Resume != nil || len(store.LoadErrors) != len(want) {
+		t.Errorf("expected only the good post to load, got %d posts, %d errors", len(store.Posts), len(store.LoadErrors))
+	}
+}
+
+func TestReload_ReusesUnchangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	post := func(title string) string {
+		return "---\ntitle: " + title + "\ndate: 2024-01-01\n---\n\nBody.\n"
+	}
+	writeContent(t, dir, map[string]string{
+		"blog/same.md":     post("Same"),
+		"blog/edited.md":   post("Edited"),
+		"projects/tool.md": post("Tool"),
+	})
+
+	prev, err := LoadFromDir(dir)
+	if err != nil {
+		t.Fatalf("LoadFromDir: %v", err)
+	}
+
+	// Mark the previous renders so reuse is observable.
+	for file, r := range prev.rendered {
+		switch item := r.item.(type) {
+		case BlogPost:
+			item.Content = "<p>cached</p>"
+			r.item = item
+		case Project:
+			item.Content = "<p>cached</p>"
+			r.item = item
+		}
+		prev.rendered[file] = r
+	}
+
+	writeContent(t, dir, map[string]string{
+		"blog/edited.md": post("Edited again"),
+	})
+
+	store, err := Reload(dir, prev)
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+
+	if got := store.PostsBySlug["same"].Content; got != "<p>cached</p>" {
+		t.Errorf("expected an unchanged post to be reused, got %q", got)
+	}
+	if got := store.ProjectsBySlug["tool"].Content; got != "<p>cached</p>" {
+		t.Errorf("expected an unchanged project to be reused, got %q", got)
+	}
+	if p := store.PostsBySlug["edited"]; p.Title != "Edited again" || p.Content == "<p>cached</p>" {
+		t.Errorf("expected a changed post to be re-rendered, got %+v", p)
+	}
+
+	// The new store carries the hashes forward for the next reload.
+	if len(store.rendered) != 3 {
+		t.Errorf("expected 3 rendered files, got %d", len(store.rendered))
+	}
+}
+
+func TestLoadFromDir_PageBundles(t *testing.T) {
+	dir := t.TempDir()
+	writeContent(t, dir, map[string]string{
+		"blog/flat.md": "---\ntitle: Flat\ndate: 2024-01-01\n---\n\n[Sibling](bundled)\n",
+		"blog/bundled/index.md": "---\ntitle: Bundled\ndate: 2024-01-02\n---\n\n" +
+			"![Diagram](diagram.png)\n\n[Data](./data/points.csv) [Flat](../flat) [Top](#top) [Site](https://example.com/x.png) [Abs](/blog)\n",
+		"blog/bundled/diagram.png":     "png",
+		"blog/bundled/data/points.csv": "1,2\n",
+		"blog/bundled/.DS_Store":       "junk",
+		"blog/notes/readme.txt":        "no index.md, so not a bundle",
+		"projects/tool/index.md":       "---\ntitle: Tool\ndate: 2024-01-01\n---\n\n![Shot](shot.png)\n",
+		"projects/tool/shot.png":       "png",
+	})
+
+	store, err := LoadFromDir(dir)
+	if err != nil {
+		t.Fatalf("LoadFromDir: %v", err)
+	}
+
+	if len(store.Posts) != 2 {
+		t.Fatalf("expected 2 posts, got %d", len(store.Posts))
+	}
+	post := store.PostsBySlug["bundled"]
+	if post == nil {
+		t.Fatal("expected the bundle to load as post \"bundled\"")
+	}
+	if len(post.Assets) != 2 || assetData(t, post.Assets["diagram.png"]) != "png" || assetData(t, post.Assets["data/points.csv"]) != "1,2\n" {
+		t.Errorf("unexpected assets: %v", post.Assets)
+	}
+
+	content := string(post.Content)
+	for _, want := range []string{
+		`src="/blog/bundled/diagram.png"`,
+		`href="/blog/bundled/data/points.csv"`,
+		`href="/blog/flat"`,
+		`href="#top"`,
+		`href="https://example.com/x.png"`,
+		`href="/blog"`,
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("expected %s in bundle content, got:\n%s", want, content)
+		}
+	}
+
+	// Relative links in flat files are left alone.
+	if flat := store.PostsBySlug["flat"]; !strings.Contains(string(flat.Content), `href="bundled"`) || flat.Assets != nil {
+		t.Errorf("expected a flat post to be unchanged, got %q", flat.Content)
+	}
+
+	proj := store.ProjectsBySlug["tool"]
+	if proj == nil || !strings.Contains(string(proj.Content), `src="/projects/tool/shot.png"`) {
+		t.Errorf("expected the project bundle's image to be rewritten, got %+v", proj)
+	}
+
+	// Changing only an asset re-renders the bundle.
+	prev := store
+	// Unchanged files aren't hashed again, so a doctored hash carries over.
+	shot := prev.ProjectsBySlug["tool"].Assets["shot.png"]
+	shot.Hash[0] ^= 0xff
+	prev.assetFiles[shot.Path] = shot
+	writeContent(t, dir, map[string]string{"blog/bundled/diagram.png": "png v2"})
+	store, err = Reload(dir, prev)
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if got := assetData(t, store.PostsBySlug["bundled"].Assets["diagram.png"]); got != "png v2" {
+		t.Errorf("expected the edited asset to be picked up, got %q", got)
+	}
+
+	// The earlier snapshot no longer serves the edited file.
+	if _, err := prev.PostsBySlug["bundled"].Assets["diagram.png"].Open(); !errors.Is(err, errAssetChanged) {
+		t.Errorf("expected the old asset to be reported as changed, got %v", err)
+	}
+	if got := store.ProjectsBySlug["tool"].Assets["shot.png"]; got != shot {
+		t.Errorf("expected the unchanged asset to be reused, got %+v", got)
+	}
+}
+
+func assetData(t *testing.T, a Asset) string {
+	t.Helper()
+	f, err := a.Open()
+	if err != nil {
+		t.Errorf("opening %s: %v", a.Path, err)
+		return ""
+	}
+	defer f.Close() //nolint:errcheck
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestLoadFromDir_BundleSlugClash(t *testing.T) {
+	dir := t.TempDir()
+	post := "---\ntitle: Post\ndate: 2024-01-01\n---\n"
+	writeContent(t, dir, map[string]string{
+		"blog/post.md":       post,
+		"blog/post/index.md": post,
+	})
+
+	if _, err := LoadFromDir(dir); err == nil || !strings.Contains(err.Error(), "clashes with") {
+		t.Fatalf("expected a slug clash error, got %v", err)
+	}
+
+	store, err := Reload(dir, nil)
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if len(store.Posts) != 1 || len(store.LoadErrors) != 1 {
+		t.Errorf("expected one post and one load error, got %d and %v", len(store.Posts), store.LoadErrors)
+	}
+}
+
+func TestReload_InvalidFrontmatter(t *testing.T) {
+	dir := t.TempDir()
+	writeContent(t, dir, map[string]string{
+		"blog/good.md":     "---\ntitle: Good\ndate: 2024-01-01\n---\n",
+		"blog/Good.md":     "---\ntitle: Shouting\ndate: 2024-01-01\n---\n",
+		"blog/untitled.md": "---\ndescription: no title or date\n---\n",
+		"blog/bare.md":     "Just text.\n",
+		"projects/bad.md":  "---\ntitle: Bad\ndate: someday\n---\n",
+	})
+
+	store, err := Reload(dir, nil)
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if len(store.Posts) != 1 || len(store.Projects) != 0 {
+		t.Errorf("expected only the good post to load, got %d posts and %d projects", len(store.Posts), len(store.Projects))
+	}
+
+	var got []string
+	for _, e := range store.LoadErrors {
+		for _, d := range e.Diagnostics() {
+			got = append(got, d.String())
+		}
+	}
+	want := []string{
+		`blog/good.md: error: slug "good" clashes with blog/Good.md`,
+		"blog/bare.md:1: error: missing frontmatter",
+		"blog/untitled.md:2: error: missing title",
+		"blog/untitled.md:2: error: missing date",
+		`projects/bad.md:3: error: invalid date "someday", want YYYY-MM-DD`,
+	}
+	slices.Sort(got)
+	slices.Sort(want)
+	if !slices.Equal(got, want) {
+		t.Errorf("unexpected load errors:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestLoadFromDir_ReservedSlugs(t *testing.T) {
+	for _, slug := range []string{"series", "tags"} {
+		t.Run(slug, func(t *testing.T) {
+			dir := t.TempDir()
+			writeContent(t, dir, map[string]string{
+				"blog/" + slug + "/index.md":  "---\ntitle: Post\ndate: 2024-01-01\n---\n\n![Chart](chart.png)\n",
+				"blog/" + slug + "/chart.png": "png",
+				"projects/" + slug + ".md":    "---\ntitle: Project\ndate: 2024-01-01\n---\n",
+			})
+
+			if _, err := LoadFromDir(dir); err == nil || !strings.Contains(err.Error(), "reserved") {
+				t.Fatalf("expected a reserved slug error, got %v", err)
+			}
+
+			store, err := Reload(dir, nil)
+			if err != nil {
+				t.Fatalf("Reload: %v", err)
+			}
+			if len(store.Posts) != 0 || len(store.LoadErrors) != 1 || store.LoadErrors[0].File != "blog/"+slug+"/index.md" {
+				t.Errorf("expected the post to be skipped, got %d posts and %v", len(store.Posts), store.LoadErrors)
+			}
+			if _, ok := store.ProjectsBySlug[slug]; !ok {
+				t.Error("expected the slug to be allowed outside the blog")
+			}
+		})
+	}
+}
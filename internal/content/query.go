@@ -0,0 +1,245 @@
+package content
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Query is a parsed search query. Its syntax is:
+//
+//	word               posts containing the word, after stemming
+//	"a phrase"         posts containing the words in order
+//	-word, -"phrase"   posts without the word or phrase
+//	a OR b             posts matching either side
+//	tag:ebpf           posts with the tag, or one of its aliases
+//	title:word         posts with the word or phrase in their title
+//	before:2024-01-01  posts from before the date, which may also be
+//	after:2023-06      given as a month or a year
+//	year:2023          posts from the year
+//
+// The last word also matches longer words it starts, so results keep up
+// as the reader types.
+//
+// Everything else must match, so "go OR rust tag:linux" finds posts
+// tagged linux that mention go or rust.
+type Query struct {
+	clauses  [][]queryAtom // every clause must match, by any of its atoms
+	excludes []queryAtom   // no atom may match
+}
+
+// maxQueryLen and maxQueryTerms bound the work a query can cause, as
+// searches come from anyone. Nobody types more than this by hand.
+const (
+	maxQueryLen   = 256 // bytes
+	maxQueryTerms = 16
+)
+
+type atomKind int
+
+const (
+	atomText atomKind = iota
+	atomTag
+	atomBefore
+	atomAfter
+	atomYear
+)
+
+// queryAtom is a single condition of a query.
+type queryAtom struct {
+	kind   atomKind
+	field  searchField // for text: the field to search, or numFields for all
+	words  []string    // for text: stemmed words, more than one for a phrase
+	prefix string      // for a final word: also match words starting with it
+	tag    string      // for tags
+	date   time.Time   // for before and after
+	year   int
+}
+
+// QueryError is a query that couldn't be parsed. Its message is written
+// to be shown to readers.
+type QueryError struct {
+	Msg string
+}
+
+func (e *QueryError) Error() string { return e.Msg }
+
+func queryErrorf(format string, args ...any) *QueryError {
+	return &QueryError{Msg: fmt.Sprintf(format, args...)}
+}
+
+// ParseQuery parses a search query. It returns a *QueryError if the query
+// is malformed, and an empty query if it has no terms.
+func ParseQuery(s string) (*Query, error) {
+	if len(s) > maxQueryLen {
+		return nil, queryErrorf("Searches can be at most %d characters long.", maxQueryLen)
+	}
+
+	q := &Query{}
+	orPending := false
+	lastNegated := false
+
+	rest := strings.TrimSpace(s)
+	for terms := 0; rest != ""; terms++ {
+		if terms == maxQueryTerms {
+			return nil, queryErrorf("Searches can have at most %d terms.", maxQueryTerms)
+		}
+		tok, remaining, err := nextQueryToken(rest)
+		if err != nil {
+			return nil, err
+		}
+		rest = strings.TrimLeftFunc(remaining, unicode.IsSpace)
+
+		if tok == "OR" {
+			switch {
+			case lastNegated:
+				return nil, queryErrorf("Excluded terms can't be combined with OR.")
+			case orPending || len(q.clauses) == 0:
+				return nil, queryErrorf("OR needs a term on each side.")
+			}
+			orPending = true
+			continue
+		}
+
+		negated := false
+		if len(tok) > 1 && tok[0] == '-' {
+			negated = true
+			tok = tok[1:]
+		}
+		atom, ok, err := parseAtom(tok)
+		if err != nil {
+			return nil, err
+		}
+		if rest == "" && !negated && atom.kind == atomText && !strings.Contains(tok, `"`) {
+			// The reader may still be typing the last word.
+			if words := splitWords(tok); len(words) == 1 && utf8.RuneCountInString(words[0]) >= minPrefix {
+				atom.prefix = words[0]
+			}
+		}
+
+		switch {
+		case negated && orPending:
+			return nil, queryErrorf("Excluded terms can't be combined with OR.")
+		case !ok:
+			// Only punctuation; there's nothing to match. An OR before it
+			// is kept for the next term.
+			lastNegated = negated
+		case negated:
+			q.excludes = append(q.excludes, atom)
+			lastNegated = true
+		case orPending:
+			last := len(q.clauses) - 1
+			q.clauses[last] = append(q.clauses[last], atom)
+			orPending, lastNegated = false, false
+		default:
+			q.clauses = append(q.clauses, []queryAtom{atom})
+			lastNegated = false
+		}
+	}
+	if orPending {
+		return nil, queryErrorf("OR needs a term on each side.")
+	}
+	return q, nil
+}
+
+// nextQueryToken splits the first token off s, which doesn't start with
+// a space. A token runs to the next space outside double quotes.
+func nextQueryToken(s string) (tok, rest string, err error) {
+	inQuote := false
+	for i, r := range s {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+		case unicode.IsSpace(r) && !inQuote:
+			return s[:i], s[i:], nil
+		}
+	}
+	if inQuote {
+		return "", "", queryErrorf("A quoted phrase is missing its closing quote.")
+	}
+	return s, "", nil
+}
+
+// parseAtom parses a token without its leading "-". It reports false if
+// the token has nothing to match, such as a lone punctuation mark.
+func parseAtom(tok string) (queryAtom, bool, error) {
+	key, value, qualified := strings.Cut(tok, ":")
+	if qualified && !strings.Contains(key, `"`) {
+		switch key = strings.ToLower(key); key {
+		case "tag", "title", "before", "after", "year":
+			value = strings.Trim(value, `"`)
+			if strings.TrimSpace(value) == "" {
+				return queryAtom{}, false, queryErrorf("%q needs a value, like %s.", key+":", qualifierExample[key])
+			}
+		default:
+			qualified = false
+		}
+	}
+	if !qualified {
+		key, value = "", tok
+	}
+
+	switch key {
+	case "tag":
+		return queryAtom{kind: atomTag, tag: value}, true, nil
+	case "before", "after":
+		start, end, ok := parseQueryDate(value)
+		if !ok {
+			return queryAtom{}, false, queryErrorf("%q needs a date like 2024-01-01, 2024-01 or 2024, not %q.", key+":", value)
+		}
+		if key == "before" {
+			return queryAtom{kind: atomBefore, date: start}, true, nil
+		}
+		return queryAtom{kind: atomAfter, date: end}, true, nil
+	case "year":
+		year, err := strconv.Atoi(value)
+		if err != nil || len(value) != 4 {
+			return queryAtom{}, false, queryErrorf("%q needs a year like 2023, not %q.", "year:", value)
+		}
+		return queryAtom{kind: atomYear, year: year}, true, nil
+	}
+
+	field := numFields
+	if key == "title" {
+		field = fieldTitle
+	}
+	words := analyze(value)
+	return queryAtom{kind: atomText, field: field, words: words}, len(words) > 0, nil
+}
+
+// qualifierExample shows how each qualifier is used, for error messages.
+var qualifierExample = map[string]string{
+	"tag":    "tag:ebpf",
+	"title":  "title:kernel",
+	"before": "before:2024-01-01",
+	"after":  "after:2023-06",
+	"year":   "year:2023",
+}
+
+// parseQueryDate parses a day, month or year, returning the start of that
+// period and the start of the next one.
+func parseQueryDate(s string) (start, end time.Time, ok bool) {
+	for _, layout := range []struct {
+		format        string
+		years, months int
+		days          int
+	}{
+		{"2006-01-02", 0, 0, 1},
+		{"2006-01", 0, 1, 0},
+		{"2006", 1, 0, 0},
+	} {
+		if t, err := time.Parse(layout.format, s); err == nil {
+			return t, t.AddDate(layout.years, layout.months, layout.days), true
+		}
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+// Empty reports whether the query has no conditions, so matches every
+// post.
+func (q *Query) Empty() bool {
+	return len(q.clauses) == 0 && len(q.excludes) == 0
+}
@@ -0,0 +1,118 @@
+package content
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseQuery_Errors(t *testing.T) {
+	tests := []struct {
+		query, msg string
+	}{
+		{`"unclosed phrase`, "missing its closing quote"},
+		{"OR go", "OR needs a term on each side"},
+		{"go OR", "OR needs a term on each side"},
+		{"go OR OR rust", "OR needs a term on each side"},
+		{"go OR -rust", "can't be combined with OR"},
+		{"-rust OR go", "can't be combined with OR"},
+		{"tag:", `"tag:" needs a value`},
+		{"before:yesterday", `"before:" needs a date`},
+		{"after:2024-13-01", `"after:" needs a date`},
+		{"year:23", `"year:" needs a year`},
+		{strings.Repeat("go ", 17), "at most 16 terms"},
+		{strings.Repeat("x", 257), "at most 256 characters"},
+	}
+	for _, tt := range tests {
+		_, err := ParseQuery(tt.query)
+		if err == nil || !strings.Contains(err.Error(), tt.msg) {
+			t.Errorf("ParseQuery(%q) error = %v, want %q", tt.query, err, tt.msg)
+		}
+	}
+}
+
+func TestParseQuery_Empty(t *testing.T) {
+	for _, query := range []string{"", "   ", "!!! -"} {
+		q, err := ParseQuery(query)
+		if err != nil {
+			t.Errorf("ParseQuery(%q): %v", query, err)
+			continue
+		}
+		if !q.Empty() {
+			t.Errorf("expected ParseQuery(%q) to be empty", query)
+		}
+	}
+}
+
+func TestSearchIndex_QueryLanguage(t *testing.T) {
+	date := func(s string) time.Time {
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	posts := []BlogPost{
+		{Slug: "probes", Title: "Kernel Probes", Date: date("2022-03-01"), Tags: []string{"ebpf"}, PlainText: "Attach a probe to the kernel function."},
+		{Slug: "seccomp", Title: "Seccomp Filters", Date: date("2022-11-15"), Tags: []string{"security"}, PlainText: "Filter system calls in the kernel."},
+		{Slug: "rust", Title: "Rust in the Kernel", Date: date("2023-05-20"), Tags: []string{"rust"}, PlainText: "Function pointers and kernel modules."},
+		{Slug: "go", Title: "Go Generics", Date: date("2024-01-10"), Tags: []string{"go"}, PlainText: "Type parameters."},
+	}
+	idx := newPostIndex(posts, func(tag string) string {
+		if tag == "bpf" {
+			return "ebpf"
+		}
+		return tag
+	})
+
+	tests := []struct {
+		query string
+		want  []string // slugs, in any order
+	}{
+		{"kernel", []string{"probes", "seccomp", "rust"}},
+		{`"kernel function"`, []string{"probes"}},
+		{`"function kernel"`, nil},
+		{"kernel -rust", []string{"probes", "seccomp"}},
+		{`kernel -"system calls"`, []string{"probes", "rust"}},
+		{"seccomp OR generics", []string{"seccomp", "go"}},
+		{"seccomp OR generics kernel", []string{"seccomp"}},
+		{"tag:bpf", []string{"probes"}},
+		{"TAG:eBPF", []string{"probes"}},
+		{"title:kernel", []string{"probes", "rust"}},
+		{`title:"in the kernel"`, []string{"rust"}},
+		{"year:2022", []string{"probes", "seccomp"}},
+		{"before:2022-11-15", []string{"probes"}},
+		{"after:2022-11-15", []string{"rust", "go"}},
+		{"after:2022", []string{"rust", "go"}},
+		{"before:2023-06 after:2022-06", []string{"seccomp", "rust"}},
+		{"-kernel", []string{"go"}},
+		{"http://example.com", nil},
+	}
+	for _, tt := range tests {
+		got := map[string]bool{}
+		for _, r := range search(t, idx, tt.query) {
+			got[r.Post.Slug] = true
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%q matched %v, want %v", tt.query, got, tt.want)
+			continue
+		}
+		for _, slug := range tt.want {
+			if !got[slug] {
+				t.Errorf("%q matched %v, want %v", tt.query, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestSearchIndex_FiltersOnlyNewestFirst(t *testing.T) {
+	posts := []BlogPost{
+		{Slug: "old", Date: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{Slug: "new", Date: time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	results := search(t, NewSearchIndex(posts), "year:2023")
+	if len(results) != 2 || results[0].Post.Slug != "new" {
+		t.Errorf("expected filter-only results newest first")
+	}
+}
@@ -1,47 +1,440 @@
 package content
 
 import (
+	"html/template"
+	"maps"
+	"math"
+	"slices"
+	"sort"
 	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/kljensen/snowball/english"
 )
 
-// SearchPosts returns posts where all whitespace-separated terms in query
-// appear (case-insensitively) in the post's title, description, tags, or body.
-// Returns nil if query is empty.
-func SearchPosts(posts []BlogPost, query string) []BlogPost {
-	terms := strings.Fields(strings.ToLower(query))
-	if len(terms) == 0 {
-		return nil
+// searchField is a part of a post that is indexed separately, so matches
+// in it can be weighted.
+type searchField int
+
+const (
+	fieldTitle searchField = iota
+	fieldTags
+	fieldDescription
+	fieldBody
+	numFields
+)
+
+// fieldWeights scales term frequencies by field, so a term in a post's
+// title or tags counts for more than one in its body.
+var fieldWeights = [numFields]float64{
+	fieldTitle:       3,
+	fieldTags:        3,
+	fieldDescription: 1.5,
+	fieldBody:        1,
+}
+
+// minPrefix is the shortest final query word that also matches the words
+// it starts, for searching as the reader types. Shorter prefixes match too
+// much to be useful.
+const minPrefix = 3
+
+// prefixWeight scales the score of a word matched by prefix.
+const prefixWeight = 0.5
+
+// BM25 parameters: bm25K1 limits how much repeating a term raises a score,
+// and bm25B how much long fields are penalized.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// SearchIndex is an inverted index of blog posts, ranking matches with
+// BM25F. A SiteIndex uses one to search projects and the résumé as well.
+type SearchIndex struct {
+	docs     []searchDoc
+	posts    []*BlogPost           // the post each doc is, for Search
+	tokens   [][numFields][]string // stemmed words of each field, by doc, for phrases
+	avgLen   [numFields]float64
+	postings map[string][]posting // stemmed term -> docs containing it
+	vocab    []string             // every word before stemming, sorted, for prefixes and typos
+	stems    map[string]string    // word in vocab -> its stem
+	byLen    map[int][]string     // words in vocab by length in runes, sorted, for typos
+
+	// canonicalTag resolves the tag in a tag: query; nil leaves it as is.
+	canonicalTag func(string) string
+}
+
+// searchDoc is something indexed: a post, or a project or résumé entry
+// in a SiteIndex.
+type searchDoc struct {
+	fields [numFields]string
+	prose  string // the body as plain text, for snippets
+	tags   []string
+	date   time.Time // zero if undated, which date qualifiers never match
+	key    string    // orders docs that rank the same
+}
+
+type posting struct {
+	doc  int // index into docs
+	freq [numFields]int
+}
+
+// searchHit is a doc matching a search.
+type searchHit struct {
+	doc     int
+	score   float64
+	snippet template.HTML
+}
+
+// SearchResult is a post matching a search.
+type SearchResult struct {
+	Post  *BlogPost
+	Score float64
+
+	// Snippet is the passage of the post's body that best matches the
+	// search, with matches marked, or empty if only its title, tags or
+	// description matched.
+	Snippet template.HTML
+}
+
+// NewSearchIndex indexes posts, which must outlive the index.
+func NewSearchIndex(posts []BlogPost) *SearchIndex {
+	return newPostIndex(posts, nil)
+}
+
+func newPostIndex(posts []BlogPost, canonicalTag func(string) string) *SearchIndex {
+	docs := make([]searchDoc, len(posts))
+	ptrs := make([]*BlogPost, len(posts))
+	for i := range posts {
+		p := &posts[i]
+		ptrs[i] = p
+		docs[i] = postDoc(p)
 	}
+	idx := newSearchIndex(docs, canonicalTag)
+	idx.posts = ptrs
+	return idx
+}
 
-	var results []BlogPost
-	for _, p := range posts {
-		text := searchableText(&p)
-		if matchesAll(text, terms) {
-			results = append(results, p)
+func postDoc(p *BlogPost) searchDoc {
+	return searchDoc{
+		fields: [numFields]string{
+			fieldTitle:       p.Title,
+			fieldTags:        strings.Join(p.Tags, " "),
+			fieldDescription: p.Description,
+			fieldBody:        p.PlainText,
+		},
+		prose: snippetText(p.PlainText),
+		tags:  p.Tags,
+		date:  p.Date,
+		key:   p.Slug,
+	}
+}
+
+func newSearchIndex(docs []searchDoc, canonicalTag func(string) string) *SearchIndex {
+	idx := &SearchIndex{
+		docs:         docs,
+		tokens:       make([][numFields][]string, len(docs)),
+		postings:     make(map[string][]posting),
+		stems:        make(map[string]string),
+		canonicalTag: canonicalTag,
+	}
+
+	var total [numFields]int
+	for i, d := range docs {
+		freqs := make(map[string]*[numFields]int)
+		for f, text := range d.fields {
+			words := splitWords(text)
+			terms := make([]string, len(words))
+			for j, w := range words {
+				if _, ok := idx.stems[w]; !ok {
+					idx.stems[w] = english.Stem(w, false)
+				}
+				terms[j] = idx.stems[w]
+			}
+			idx.tokens[i][f] = terms
+			total[f] += len(terms)
+			for _, t := range terms {
+				if freqs[t] == nil {
+					freqs[t] = new([numFields]int)
+				}
+				freqs[t][f]++
+			}
+		}
+		for t, freq := range freqs {
+			idx.postings[t] = append(idx.postings[t], posting{doc: i, freq: *freq})
+		}
+	}
+
+	idx.vocab = slices.Sorted(maps.Keys(idx.stems))
+	idx.byLen = make(map[int][]string)
+	for _, w := range idx.vocab {
+		n := utf8.RuneCountInString(w)
+		idx.byLen[n] = append(idx.byLen[n], w)
+	}
+	if len(docs) > 0 {
+		for f := range total {
+			idx.avgLen[f] = float64(total[f]) / float64(len(docs))
 		}
 	}
+	return idx
+}
+
+// Search returns the posts matching q, best match first. Words are
+// matched after stemming, so "tracing" finds "traces". Posts matched only
+// by qualifiers such as year:, which don't score, are newest first. It
+// returns nil if q is empty or idx is nil.
+func (idx *SearchIndex) Search(q *Query) []SearchResult {
+	if idx == nil {
+		return nil
+	}
+	hits := idx.search(q)
+	if hits == nil {
+		return nil
+	}
+	results := make([]SearchResult, len(hits))
+	for i, h := range hits {
+		results[i] = SearchResult{Post: idx.posts[h.doc], Score: h.score, Snippet: h.snippet}
+	}
 	return results
 }
 
-func searchableText(p *BlogPost) string {
-	var b strings.Builder
-	b.WriteString(strings.ToLower(p.Title))
-	b.WriteByte(' ')
-	b.WriteString(strings.ToLower(p.Description))
-	for _, tag := range p.Tags {
-		b.WriteByte(' ')
-		b.WriteString(strings.ToLower(tag))
+// search returns the docs matching q, best match first, or nil if q is
+// empty.
+func (idx *SearchIndex) search(q *Query) []searchHit {
+	if q == nil || q.Empty() {
+		return nil
 	}
-	b.WriteByte(' ')
-	b.WriteString(strings.ToLower(p.PlainText))
-	return b.String()
+
+	var scores map[int]float64 // nil until a clause narrows the candidates
+	for _, clause := range q.clauses {
+		matches := make(map[int]float64)
+		for _, a := range clause {
+			for doc, score := range idx.match(a) {
+				matches[doc] += score
+			}
+		}
+		if scores == nil {
+			scores = matches
+			continue
+		}
+		for doc := range scores {
+			if score, ok := matches[doc]; ok {
+				scores[doc] += score
+			} else {
+				delete(scores, doc)
+			}
+		}
+	}
+	if scores == nil {
+		scores = make(map[int]float64, len(idx.docs))
+		for doc := range idx.docs {
+			scores[doc] = 0
+		}
+	}
+	for _, a := range q.excludes {
+		for doc := range idx.match(a) {
+			delete(scores, doc)
+		}
+	}
+
+	h := newHighlighter(q)
+	hits := make([]searchHit, 0, len(scores))
+	for doc, score := range scores {
+		hit := searchHit{doc: doc, score: score}
+		if !h.empty() {
+			hit.snippet = idx.snippet(idx.docs[doc].prose, h)
+		}
+		hits = append(hits, hit)
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		a, b := hits[i], hits[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		da, db := idx.docs[a.doc], idx.docs[b.doc]
+		if !da.date.Equal(db.date) {
+			return da.date.After(db.date)
+		}
+		return da.key < db.key
+	})
+	return hits
+}
+
+// match returns the docs matching a, with the score a gives each.
+func (idx *SearchIndex) match(a queryAtom) map[int]float64 {
+	if a.kind == atomText {
+		return idx.matchText(a)
+	}
+
+	tag := a.tag
+	if a.kind == atomTag && idx.canonicalTag != nil {
+		tag = idx.canonicalTag(tag)
+	}
+	docs := make(map[int]float64)
+	for doc, d := range idx.docs {
+		var ok bool
+		switch a.kind {
+		case atomTag:
+			ok = slices.ContainsFunc(d.tags, func(t string) bool { return foldTag(t) == foldTag(tag) })
+		case atomBefore:
+			ok = !d.date.IsZero() && d.date.Before(a.date)
+		case atomAfter:
+			ok = !d.date.Before(a.date)
+		case atomYear:
+			ok = d.date.Year() == a.year
+		}
+		if ok {
+			docs[doc] = 0
+		}
+	}
+	return docs
+}
+
+// matchText returns the posts containing every word of a text atom, in
+// order if it is a phrase, scored by the sum of the words' scores.
+func (idx *SearchIndex) matchText(a queryAtom) map[int]float64 {
+	if a.prefix != "" {
+		return idx.matchPrefix(a)
+	}
+
+	var docs map[int]float64
+	for i, w := range a.words {
+		found := make(map[int]float64)
+		for _, p := range idx.postings[w] {
+			if a.field != numFields && p.freq[a.field] == 0 {
+				continue
+			}
+			if prev, ok := docs[p.doc]; ok || i == 0 {
+				found[p.doc] = prev + idx.score(w, p, a.field)
+			}
+		}
+		docs = found
+	}
+	if len(a.words) > 1 {
+		for doc := range docs {
+			if !idx.hasPhrase(doc, a.words, a.field) {
+				delete(docs, doc)
+			}
+		}
+	}
+	return docs
+}
+
+// matchPrefix returns the posts containing a word starting with a's
+// prefix. Words other than the prefix itself score less, so a complete
+// word still ranks its own matches first.
+func (idx *SearchIndex) matchPrefix(a queryAtom) map[int]float64 {
+	docs := make(map[int]float64)
+	seen := make(map[string]bool)
+	for _, w := range idx.withPrefix(a.prefix) {
+		stem := idx.stems[w]
+		if seen[stem] {
+			continue
+		}
+		seen[stem] = true
+		weight := prefixWeight
+		if stem == a.words[0] {
+			weight = 1
+		}
+		for _, p := range idx.postings[stem] {
+			if a.field != numFields && p.freq[a.field] == 0 {
+				continue
+			}
+			docs[p.doc] = max(docs[p.doc], weight*idx.score(stem, p, a.field))
+		}
+	}
+	// The prefix may stem differently from every word it starts, as
+	// "running" does from "runner".
+	if !seen[a.words[0]] {
+		for doc, score := range idx.matchText(queryAtom{kind: atomText, field: a.field, words: a.words}) {
+			docs[doc] = max(docs[doc], score)
+		}
+	}
+	return docs
+}
+
+// withPrefix returns the words in the index starting with prefix.
+func (idx *SearchIndex) withPrefix(prefix string) []string {
+	i := sort.SearchStrings(idx.vocab, prefix)
+	j := i
+	for j < len(idx.vocab) && strings.HasPrefix(idx.vocab[j], prefix) {
+		j++
+	}
+	return idx.vocab[i:j]
+}
+
+// hasPhrase reports whether words appear consecutively in a field of the
+// post, or in field only unless it is numFields.
+func (idx *SearchIndex) hasPhrase(doc int, words []string, field searchField) bool {
+	for f, tokens := range idx.tokens[doc] {
+		if field != numFields && searchField(f) != field {
+			continue
+		}
+		for i := 0; i+len(words) <= len(tokens); i++ {
+			if slices.Equal(tokens[i:i+len(words)], words) {
+				return true
+			}
+		}
+	}
+	return false
 }
 
-func matchesAll(text string, terms []string) bool {
-	for _, t := range terms {
-		if !strings.Contains(text, t) {
-			return false
+// score is the BM25F score of term t in the post of posting p, counting
+// only field unless it is numFields.
+func (idx *SearchIndex) score(t string, p posting, field searchField) float64 {
+	var tf float64
+	for f := range numFields {
+		if p.freq[f] == 0 || (field != numFields && f != field) {
+			continue
 		}
+		norm := 1 - bm25B
+		if idx.avgLen[f] > 0 {
+			norm += bm25B * float64(len(idx.tokens[p.doc][f])) / idx.avgLen[f]
+		}
+		tf += fieldWeights[f] * float64(p.freq[f]) / norm
 	}
-	return true
+
+	n := float64(len(idx.postings[t]))
+	idf := math.Log(1 + (float64(len(idx.docs))-n+0.5)/(n+0.5))
+	return idf * tf / (bm25K1 + tf)
+}
+
+// splitWords splits text into lower-cased words.
+func splitWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), isWordSeparator)
+}
+
+func isWordSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// analyze splits text into lower-cased words and stems them.
+func analyze(text string) []string {
+	words := splitWords(text)
+	for i, w := range words {
+		words[i] = english.Stem(w, false)
+	}
+	return words
+}
+
+// SearchPosts returns the posts matching query, best match first, as
+// SearchIndex.Search does. It builds a throwaway index, so callers with a
+// ContentStore should use its Search index instead. Returns nil if query
+// is empty or malformed.
+func SearchPosts(posts []BlogPost, query string) []BlogPost {
+	q, err := ParseQuery(query)
+	if err != nil {
+		return nil
+	}
+	hits := NewSearchIndex(posts).Search(q)
+	if hits == nil {
+		return nil
+	}
+	results := make([]BlogPost, len(hits))
+	for i, h := range hits {
+		results[i] = *h.Post
+	}
+	return results
 }
@@ -2,6 +2,15 @@ package content
 
 import "testing"
 
+func search(t *testing.T, idx *SearchIndex, query string) []SearchResult {
+	t.Helper()
+	q, err := ParseQuery(query)
+	if err != nil {
+		t.Fatalf("ParseQuery(%q): %v", query, err)
+	}
+	return idx.Search(q)
+}
+
 func TestSearchPosts_MatchTitle(t *testing.T) {
 	posts := []BlogPost{
 		{Title: "Go Concurrency Patterns", PlainText: "body text"},
@@ -85,3 +94,52 @@ func TestSearchPosts_NoMatches(t *testing.T) {
 		t.Errorf("expected 0 results, got %d", len(results))
 	}
 }
+
+func TestSearchPosts_Stemming(t *testing.T) {
+	posts := []BlogPost{
+		{Title: "Post", PlainText: "Tracing syscalls with eBPF."},
+	}
+	if results := SearchPosts(posts, "traces syscall"); len(results) != 1 {
+		t.Errorf("expected stemmed terms to match, got %d results", len(results))
+	}
+}
+
+func TestSearchIndex_RanksTitleAboveBody(t *testing.T) {
+	posts := []BlogPost{
+		{Slug: "body", Title: "Kernel Notes", PlainText: "A short aside on seccomp filters."},
+		{Slug: "title", Title: "Seccomp in Practice", PlainText: "Sandboxing processes."},
+		{Slug: "tag", Title: "Sandboxing", Tags: []string{"seccomp"}, PlainText: "Containers."},
+		{Slug: "none", Title: "Unrelated", PlainText: "Nothing here."},
+	}
+	results := search(t, NewSearchIndex(posts), "seccomp")
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+	if results[2].Post.Slug != "body" {
+		t.Errorf("expected the body-only match last, got order %s, %s, %s",
+			results[0].Post.Slug, results[1].Post.Slug, results[2].Post.Slug)
+	}
+	for i := 1; i < len(results); i++ {
+		if results[i].Score > results[i-1].Score {
+			t.Errorf("results not sorted by score: %v", results)
+		}
+	}
+}
+
+func TestSearchIndex_RepeatedTermsRankHigher(t *testing.T) {
+	posts := []BlogPost{
+		{Slug: "once", Title: "A", PlainText: "namespaces are one topic among many others here"},
+		{Slug: "often", Title: "B", PlainText: "namespaces, namespaces and more namespaces here"},
+	}
+	results := search(t, NewSearchIndex(posts), "namespaces")
+	if len(results) != 2 || results[0].Post.Slug != "often" {
+		t.Errorf("expected the post mentioning the term most first")
+	}
+}
+
+func TestSearchIndex_Nil(t *testing.T) {
+	var idx *SearchIndex
+	if results := search(t, idx, "anything"); results != nil {
+		t.Errorf("expected nil from a nil index, got %v", results)
+	}
+}
@@ -0,0 +1,86 @@
+package content
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PostSeries places a post in a series of posts.
+type PostSeries struct {
+	Name  string `yaml:"name"`
+	Order int    `yaml:"order"` // position in the series; 0 orders by date
+}
+
+// UnmarshalYAML allows a series to be given as just its name, or as an
+// object with "name" and optional "order" fields.
+func (s *PostSeries) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		s.Name = value.Value
+		return nil
+	}
+
+	var obj struct {
+		Name  string `yaml:"name"`
+		Order int    `yaml:"order"`
+	}
+	if err := value.Decode(&obj); err != nil {
+		return fmt.Errorf("decoding series: %w", err)
+	}
+	*s = PostSeries(obj)
+	return nil
+}
+
+// Slug returns the series' URL path segment.
+func (s *PostSeries) Slug() string {
+	return SeriesSlug(s.Name)
+}
+
+// SeriesSlug turns a series name into a URL path segment: lower-cased,
+// with each run of other characters than letters and digits made a dash.
+func SeriesSlug(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return b.String()
+}
+
+// indexSeries fills in PostsBySeries from the published posts. Parts are
+// in reading order: by their order field, then oldest first.
+func (cs *ContentStore) indexSeries() {
+	for i := range cs.Posts {
+		p := &cs.Posts[i]
+		if p.Series == nil || p.Series.Slug() == "" {
+			continue
+		}
+		slug := p.Series.Slug()
+		cs.PostsBySeries[slug] = append(cs.PostsBySeries[slug], p)
+	}
+
+	for _, posts := range cs.PostsBySeries {
+		sort.SliceStable(posts, func(i, j int) bool {
+			a, b := posts[i], posts[j]
+			// Posts without an order come after those with one.
+			if (a.Series.Order == 0) != (b.Series.Order == 0) {
+				return b.Series.Order == 0
+			}
+			if a.Series.Order != b.Series.Order {
+				return a.Series.Order < b.Series.Order
+			}
+			return a.Date.Before(b.Date)
+		})
+	}
+}
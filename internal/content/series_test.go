@@ -0,0 +1,58 @@
+package content
+
+import "testing"
+
+func TestSeriesSlug(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"eBPF Deep Dive", "ebpf-deep-dive"},
+		{"  Rust: Part  Two! ", "rust-part-two"},
+		{"café", "café"},
+		{"---", ""},
+	}
+	for _, tt := range tests {
+		if got := SeriesSlug(tt.name); got != tt.want {
+			t.Errorf("SeriesSlug(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLoadFromDir_Series(t *testing.T) {
+	dir := t.TempDir()
+	writeContent(t, dir, map[string]string{
+		"blog/intro.md":   "---\ntitle: Intro\ndate: 2024-03-01\nseries: eBPF Deep Dive\n---\n",
+		"blog/maps.md":    "---\ntitle: Maps\ndate: 2024-01-01\nseries:\n  name: eBPF Deep Dive\n  order: 2\n---\n",
+		"blog/basics.md":  "---\ntitle: Basics\ndate: 2024-02-01\nseries:\n  name: eBPF Deep Dive\n  order: 1\n---\n",
+		"blog/draft.md":   "---\ntitle: Draft\ndate: 2024-04-01\ndraft: true\nseries: eBPF Deep Dive\n---\n",
+		"blog/lone.md":    "---\ntitle: Lone\ndate: 2024-01-01\n---\n",
+		"blog/another.md": "---\ntitle: Another\ndate: 2024-01-01\nseries: Other\n---\n",
+	})
+
+	store, err := LoadFromDir(dir)
+	if err != nil {
+		t.Fatalf("LoadFromDir: %v", err)
+	}
+
+	parts := store.PostsBySeries["ebpf-deep-dive"]
+	var got []string
+	for _, p := range parts {
+		got = append(got, p.Slug)
+	}
+	want := []string{"basics", "maps", "intro"}
+	if len(got) != len(want) {
+		t.Fatalf("parts = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("parts = %v, want %v", got, want)
+		}
+	}
+
+	if s := store.PostsBySlug["maps"].Series; s.Name != "eBPF Deep Dive" || s.Order != 2 {
+		t.Errorf("maps series = %+v", s)
+	}
+	if len(store.PostsBySeries) != 2 {
+		t.Errorf("expected 2 series, got %d", len(store.PostsBySeries))
+	}
+}
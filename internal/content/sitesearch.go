@@ -0,0 +1,180 @@
+package content
+
+import (
+	"html/template"
+	"strings"
+	"time"
+)
+
+// SiteKind is the kind of page a site search result is on.
+type SiteKind string
+
+const (
+	KindPost    SiteKind = "post"
+	KindProject SiteKind = "project"
+	KindResume  SiteKind = "resume"
+)
+
+// SiteResult is a post, project or résumé entry matching a site search.
+type SiteResult struct {
+	Kind        SiteKind
+	Title       string
+	Description string
+	URL         string // path of the page, with the entry's anchor on /resume
+	Tags        []string
+	Date        time.Time // for posts and projects
+	Section     string    // for résumé entries: the résumé section
+	Dates       string    // for résumé entries: their dates as the résumé shows them
+	Score       float64
+
+	// Snippet is the passage of the body that best matches the search, as
+	// for SearchResult. Résumé entries' bodies are their bullets.
+	Snippet template.HTML
+}
+
+// SiteIndex searches blog posts, projects and the résumé's experience,
+// presentations, publications and open-source projects together.
+type SiteIndex struct {
+	idx     *SearchIndex
+	results []SiteResult // by doc, without a score or snippet
+}
+
+func newSiteIndex(cs *ContentStore) *SiteIndex {
+	var docs []searchDoc
+	s := &SiteIndex{}
+	add := func(d searchDoc, r SiteResult) {
+		docs = append(docs, d)
+		s.results = append(s.results, r)
+	}
+
+	for i := range cs.Posts {
+		p := &cs.Posts[i]
+		add(postDoc(p), SiteResult{
+			Kind:        KindPost,
+			Title:       p.Title,
+			Description: p.Description,
+			URL:         "/blog/" + p.Slug,
+			Tags:        p.Tags,
+			Date:        p.Date,
+		})
+	}
+	for i := range cs.Projects {
+		p := &cs.Projects[i]
+		add(searchDoc{
+			fields: [numFields]string{
+				fieldTitle:       p.Title,
+				fieldTags:        strings.Join(p.Tags, " "),
+				fieldDescription: p.Description,
+				fieldBody:        p.PlainText,
+			},
+			prose: snippetText(p.PlainText),
+			tags:  p.Tags,
+			date:  p.Date,
+			key:   "/projects/" + p.Slug,
+		}, SiteResult{
+			Kind:        KindProject,
+			Title:       p.Title,
+			Description: p.Description,
+			URL:         "/projects/" + p.Slug,
+			Tags:        p.Tags,
+			Date:        p.Date,
+		})
+	}
+	if r := cs.Resume; r != nil {
+		addResume := func(section, id, title, description, body string, date ResumeDate, dates string) {
+			url := "/resume#" + id
+			add(searchDoc{
+				fields: [numFields]string{
+					fieldTitle:       title,
+					fieldDescription: description,
+					fieldBody:        body,
+				},
+				prose: body,
+				date:  date.Time(),
+				key:   url,
+			}, SiteResult{
+				Kind:        KindResume,
+				Title:       title,
+				Description: description,
+				URL:         url,
+				Section:     section,
+				Dates:       dates,
+			})
+		}
+		for _, e := range r.Experience {
+			where := joinNonEmpty(", ", e.Organization, e.Location)
+			addResume("Experience", e.ID, e.Title, joinNonEmpty(". ", where, e.Note), bulletText(e.Bullets), e.Start, e.DateRange)
+		}
+		for _, p := range r.Presentations {
+			addResume("Presentations", p.ID, p.Title, snippetText(p.RawVenue), "", p.Date, p.DateFormatted)
+		}
+		for _, sec := range r.Publications {
+			for j, raw := range sec.RawItems {
+				addResume("Publications", sec.ItemIDs[j], snippetText(raw), sec.Section, "", ResumeDate{}, "")
+			}
+		}
+		for _, sec := range r.OpenSource {
+			for _, p := range sec.Projects {
+				body := make([]string, len(p.RawBullets))
+				for k, raw := range p.RawBullets {
+					body[k] = snippetText(raw)
+				}
+				addResume("Open Source", p.ID, p.Name, p.Tagline, strings.Join(body, " "), ResumeDate{}, "")
+			}
+		}
+	}
+
+	s.idx = newSearchIndex(docs, cs.CanonicalTag)
+	return s
+}
+
+// Search returns the posts, projects and résumé entries matching q, best
+// match first, as SearchIndex.Search does. Résumé entries have no tags,
+// and only experience and presentations have dates for date qualifiers.
+func (s *SiteIndex) Search(q *Query) []SiteResult {
+	if s == nil {
+		return nil
+	}
+	hits := s.idx.search(q)
+	if hits == nil {
+		return nil
+	}
+	results := make([]SiteResult, len(hits))
+	for i, h := range hits {
+		results[i] = s.results[h.doc]
+		results[i].Score = h.score
+		results[i].Snippet = h.snippet
+	}
+	return results
+}
+
+// Suggest corrects query against the words of everything indexed, as
+// SearchIndex.Suggest does.
+func (s *SiteIndex) Suggest(query string) string {
+	if s == nil {
+		return ""
+	}
+	return s.idx.Suggest(query)
+}
+
+// bulletText returns the plain text of bullets and their sub-bullets.
+func bulletText(bullets []ResumeBullet) string {
+	var parts []string
+	for _, b := range bullets {
+		parts = append(parts, snippetText(b.RawText))
+		if sub := bulletText(b.RawSub); sub != "" {
+			parts = append(parts, sub)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func joinNonEmpty(sep string, parts ...string) string {
+	var nonEmpty []string
+	for _, p := range parts {
+		if p != "" {
+			nonEmpty = append(nonEmpty, p)
+		}
+	}
+	return strings.Join(nonEmpty, sep)
+}
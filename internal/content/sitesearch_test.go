@@ -0,0 +1,151 @@
+package content
+
+import (
+	"strings"
+	"testing"
+)
+
+const siteSearchResume = `name: Me
+experience:
+  - title: Software Engineer
+    organization: Acme
+    location: Toronto
+    start: {year: 2022}
+    bullets:
+      - Built eBPF tooling in Go
+      - text: Led the kernel team
+        sub:
+          - Reviewed seccomp patches
+presentations:
+  - title: Tracing with eBPF
+    venue: "[Linux Plumbers](https://lpc.events)"
+    date: {year: 2023, month: 9}
+publications:
+  - section: Conference Papers
+    items:
+      - "**Me**. *Container isolation with eBPF*. USENIX, 2021."
+      - "**Me**. *Another paper*. 2020."
+opensource:
+  - section: Maintainer
+    projects:
+      - name: bpfbox
+        tagline: Process confinement
+        bullets:
+          - Written in Rust and eBPF
+`
+
+func loadSiteSearch(t *testing.T) *ContentStore {
+	t.Helper()
+	dir := t.TempDir()
+	writeContent(t, dir, map[string]string{
+		"blog/tracing.md":     "---\ntitle: Tracing\ndate: 2024-01-01\ntags: [ebpf]\n---\n\nTracing the kernel.\n",
+		"projects/sandbox.md": "---\ntitle: Sandbox\ndate: 2023-01-01\ndescription: A sandbox\ntags: [rust, ebpf]\n---\n\nConfines processes with seccomp.\n",
+		"projects/website.md": "---\ntitle: Website\ndate: 2022-01-01\ntags: [go]\n---\n\nThis site.\n",
+		"resume/resume.yaml":  siteSearchResume,
+	})
+	store, err := LoadFromDir(dir)
+	if err != nil {
+		t.Fatalf("LoadFromDir: %v", err)
+	}
+	return store
+}
+
+func siteSearch(t *testing.T, idx *SiteIndex, query string) []SiteResult {
+	t.Helper()
+	q, err := ParseQuery(query)
+	if err != nil {
+		t.Fatalf("ParseQuery(%q): %v", query, err)
+	}
+	return idx.Search(q)
+}
+
+func siteURLs(results []SiteResult) []string {
+	urls := make([]string, len(results))
+	for i, r := range results {
+		urls[i] = r.URL
+	}
+	return urls
+}
+
+func TestLoadFromDir_ResumeIDs(t *testing.T) {
+	r := loadSiteSearch(t).Resume
+
+	if got := r.Experience[0].ID; got != "experience-software-engineer-acme" {
+		t.Errorf("experience ID = %q", got)
+	}
+	if got := r.Presentations[0].ID; got != "talk-tracing-with-ebpf" {
+		t.Errorf("presentation ID = %q", got)
+	}
+	if got := r.Publications[0].ItemIDs; len(got) != 2 || got[0] != "publication-1" || got[1] != "publication-2" {
+		t.Errorf("publication IDs = %v", got)
+	}
+	if got := r.OpenSource[0].Projects[0].ID; got != "oss-bpfbox" {
+		t.Errorf("open source ID = %q", got)
+	}
+}
+
+func TestSetResumeIDs_Duplicates(t *testing.T) {
+	r := &Resume{
+		Experience:    []ResumeEntry{{Title: "Intern", Organization: "Acme"}, {Title: "Intern", Organization: "Acme"}},
+		Presentations: []ResumePresentation{{Title: "!!!"}},
+	}
+	setResumeIDs(r)
+	if got := r.Experience[1].ID; got != "experience-intern-acme-2" {
+		t.Errorf("expected a numbered ID for a repeated entry, got %q", got)
+	}
+	if got := r.Presentations[0].ID; got != "talk" {
+		t.Errorf("expected the bare prefix for an untitled entry, got %q", got)
+	}
+}
+
+func TestSiteIndex_Search(t *testing.T) {
+	store := loadSiteSearch(t)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"rust", []string{"/projects/sandbox", "/resume#oss-bpfbox"}},
+		{"tag:ebpf", []string{"/blog/tracing", "/projects/sandbox"}},
+		{"seccomp", []string{"/projects/sandbox", "/resume#experience-software-engineer-acme"}},
+		{"plumbers", []string{"/resume#talk-tracing-with-ebpf"}},
+		{`"container isolation"`, []string{"/resume#publication-1"}},
+		{"year:2023 ebpf", []string{"/resume#talk-tracing-with-ebpf", "/projects/sandbox"}},
+		{"before:2021 paper", nil},
+	}
+	for _, tt := range tests {
+		got := siteURLs(siteSearch(t, store.SiteSearch, tt.query))
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestSiteIndex_Results(t *testing.T) {
+	store := loadSiteSearch(t)
+
+	results := siteSearch(t, store.SiteSearch, "kernel")
+	var exp *SiteResult
+	for i := range results {
+		if results[i].Kind == KindResume {
+			exp = &results[i]
+		}
+	}
+	if exp == nil {
+		t.Fatalf("expected a résumé result, got %v", siteURLs(results))
+	}
+	if exp.Section != "Experience" || exp.Dates != "2022 – Present" || exp.Description != "Acme, Toronto" {
+		t.Errorf("unexpected résumé result %+v", *exp)
+	}
+	if !strings.Contains(string(exp.Snippet), "Led the <mark>kernel</mark> team") {
+		t.Errorf("expected a snippet of the bullets, got %q", exp.Snippet)
+	}
+
+	if got := store.SiteSearch.Suggest("sandbx"); got != "sandbox" {
+		t.Errorf("Suggest = %q, want sandbox", got)
+	}
+	var nilIdx *SiteIndex
+	if nilIdx.Search(&Query{}) != nil || nilIdx.Suggest("x") != "" {
+		t.Error("expected a nil index to find nothing")
+	}
+}
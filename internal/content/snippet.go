@@ -0,0 +1,176 @@
+package content
+
+import (
+	"html/template"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/kljensen/snowball/english"
+)
+
+// snippetWords is the length of a search result snippet, in words, and
+// snippetLead how many of them come before the first match if possible.
+const (
+	snippetWords = 30
+	snippetLead  = 5
+)
+
+var (
+	mdImageRE  = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	mdLinkRE   = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	mdLineRE   = regexp.MustCompile(`(?m)^\s*(#+|>|[-*+]|\d+\.)\s+`)
+	mdEmphasis = strings.NewReplacer("**", "", "__", "", "`", "")
+)
+
+// snippetText reduces a post's markdown body to the plain prose snippets
+// are cut from: code blocks are dropped, links and images become their
+// text, and heading, quote and list markers and emphasis are removed.
+func snippetText(markdown string) string {
+	s := stripCodeBlocks(markdown)
+	s = mdImageRE.ReplaceAllString(s, "$1")
+	s = mdLinkRE.ReplaceAllString(s, "$1")
+	s = mdLineRE.ReplaceAllString(s, "")
+	s = mdEmphasis.Replace(s)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// highlighter matches the words a query searched post bodies for.
+type highlighter struct {
+	stems    map[string]bool
+	prefixes []string
+}
+
+// newHighlighter collects the words of q's text conditions, leaving out
+// exclusions and title: conditions, which say nothing about the body.
+func newHighlighter(q *Query) *highlighter {
+	h := &highlighter{stems: make(map[string]bool)}
+	for _, clause := range q.clauses {
+		for _, a := range clause {
+			if a.kind != atomText || a.field == fieldTitle {
+				continue
+			}
+			for _, w := range a.words {
+				h.stems[w] = true
+			}
+			if a.prefix != "" {
+				h.prefixes = append(h.prefixes, a.prefix)
+			}
+		}
+	}
+	return h
+}
+
+func (h *highlighter) empty() bool {
+	return len(h.stems) == 0 && len(h.prefixes) == 0
+}
+
+// match returns the term a lower-cased word matches, or "" if none. Words
+// matched by a prefix count as the prefix.
+func (h *highlighter) match(word, stem string) string {
+	if h.stems[stem] {
+		return stem
+	}
+	for _, p := range h.prefixes {
+		if strings.HasPrefix(word, p) {
+			return p
+		}
+	}
+	return ""
+}
+
+// snippet returns the passage of text with the most distinct matched
+// terms, then the most matches, with the matches wrapped in <mark>. It
+// returns "" if nothing in text matches.
+func (idx *SearchIndex) snippet(text string, h *highlighter) template.HTML {
+	type span struct {
+		start, end int    // byte offsets in text
+		term       string // matched term, or ""
+	}
+	var words []span
+	for i := 0; i < len(text); {
+		j := strings.IndexFunc(text[i:], func(r rune) bool { return !isWordSeparator(r) })
+		if j < 0 {
+			break
+		}
+		start := i + j
+		end := strings.IndexFunc(text[start:], isWordSeparator)
+		if end < 0 {
+			end = len(text)
+		} else {
+			end += start
+		}
+		word := strings.ToLower(text[start:end])
+		stem, ok := idx.stems[word]
+		if !ok {
+			stem = english.Stem(word, false)
+		}
+		words = append(words, span{start: start, end: end, term: h.match(word, stem)})
+		i = end
+	}
+
+	// Slide a window over the words, keeping the best start.
+	n := min(snippetWords, len(words))
+	counts := make(map[string]int)
+	matches := 0
+	add := func(s span, d int) {
+		if s.term == "" {
+			return
+		}
+		counts[s.term] += d
+		if counts[s.term] == 0 {
+			delete(counts, s.term)
+		}
+		matches += d
+	}
+	for _, s := range words[:n] {
+		add(s, 1)
+	}
+	best, bestDistinct, bestMatches := 0, len(counts), matches
+	for start := 1; start+n <= len(words); start++ {
+		add(words[start-1], -1)
+		add(words[start+n-1], 1)
+		if len(counts) > bestDistinct || (len(counts) == bestDistinct && matches > bestMatches) {
+			best, bestDistinct, bestMatches = start, len(counts), matches
+		}
+	}
+	if bestMatches == 0 {
+		return ""
+	}
+	// Moving the window later to open a few words before its first match
+	// can't lose a match, only words of context at the start.
+	first := best
+	for words[first].term == "" {
+		first++
+	}
+	best = min(max(best, first-snippetLead), len(words)-n)
+
+	var b strings.Builder
+	if best > 0 {
+		b.WriteString("… ")
+	}
+	window := words[best : best+n]
+	pos := window[0].start
+	for _, s := range window {
+		if s.term == "" {
+			continue
+		}
+		b.WriteString(template.HTMLEscapeString(text[pos:s.start]))
+		b.WriteString("<mark>")
+		b.WriteString(template.HTMLEscapeString(text[s.start:s.end]))
+		b.WriteString("</mark>")
+		pos = s.end
+	}
+	// Keep punctuation closing the last word, such as a full stop.
+	last := window[len(window)-1].end
+	if end := strings.IndexFunc(text[last:], unicode.IsSpace); end >= 0 {
+		last += end
+	} else {
+		last = len(text)
+	}
+	b.WriteString(template.HTMLEscapeString(text[pos:last]))
+	if best+n < len(words) {
+		b.WriteString(" …")
+	}
+	return template.HTML(b.String())
+}
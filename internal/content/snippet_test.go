@@ -0,0 +1,58 @@
+package content
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSnippetText(t *testing.T) {
+	md := "# Heading\n\nSome **bold** and `code` with a [link](https://example.com).\n\n```go\nfunc hidden() {}\n```\n\n- item ![alt](x.png)\n> quote\n"
+	want := "Heading Some bold and code with a link. item alt quote"
+	if got := snippetText(md); got != want {
+		t.Errorf("snippetText = %q, want %q", got, want)
+	}
+}
+
+func TestSearchIndex_Snippets(t *testing.T) {
+	filler := strings.Repeat("lorem ipsum dolor sit amet ", 20)
+	posts := []BlogPost{
+		{Slug: "long", Title: "Sandboxing", PlainText: filler + "Here seccomp filters <limit> system calls. " + filler},
+		{Slug: "title", Title: "Seccomp", PlainText: "Nothing relevant here."},
+		{Slug: "code", Title: "Code", PlainText: "Intro.\n\n```\nseccomp()\n```\n\nMore about seccomp outside code."},
+	}
+	idx := NewSearchIndex(posts)
+
+	snippets := map[string]string{}
+	for _, r := range search(t, idx, "seccomp filter") {
+		snippets[r.Post.Slug] = string(r.Snippet)
+	}
+	long := snippets["long"]
+	if !strings.Contains(long, "<mark>seccomp</mark> <mark>filters</mark> &lt;limit&gt; system calls") {
+		t.Errorf("expected marked and escaped matches, got %q", long)
+	}
+	if !strings.HasPrefix(long, "… ") || !strings.HasSuffix(long, " …") {
+		t.Errorf("expected ellipses around a passage from the middle, got %q", long)
+	}
+	if !strings.HasPrefix(long, "… ipsum dolor sit amet Here <mark>seccomp</mark>") {
+		t.Errorf("expected a few words before the first match, got %q", long)
+	}
+
+	for _, r := range search(t, idx, "seccomp") {
+		switch r.Post.Slug {
+		case "title":
+			if r.Snippet != "" {
+				t.Errorf("expected no snippet for a title-only match, got %q", r.Snippet)
+			}
+		case "code":
+			if got := string(r.Snippet); got != "Intro. More about <mark>seccomp</mark> outside code." {
+				t.Errorf("expected the snippet to skip code blocks, got %q", got)
+			}
+		}
+	}
+
+	for _, r := range search(t, idx, "year:2024") {
+		if r.Snippet != "" {
+			t.Errorf("expected no snippet without search terms, got %q", r.Snippet)
+		}
+	}
+}
@@ -1,15 +1,37 @@
 package content
 
 import (
+	"errors"
+	"sync"
 	"sync/atomic"
 )
 
+// DefaultHistory is how many loaded snapshots an AtomicStore keeps for
+// rolling back to, including the current one.
+const DefaultHistory = 5
+
+// ErrNoSnapshot is returned by Rollback when there is nothing to roll back to.
+var ErrNoSnapshot = errors.New("no such content snapshot")
+
 type AtomicStore struct {
 	ptr atomic.Pointer[ContentStore]
+
+	mu      sync.Mutex
+	limit   int
+	history []*ContentStore // oldest first; the last entry is current
 }
 
 func NewAtomicStore() *AtomicStore {
-	return &AtomicStore{}
+	return &AtomicStore{limit: DefaultHistory}
+}
+
+// SetHistoryLimit changes how many snapshots are kept. Values below 1 are
+// treated as 1.
+func (s *AtomicStore) SetHistoryLimit(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limit = max(n, 1)
+	s.trim()
 }
 
 func (s *AtomicStore) Load() *ContentStore {
@@ -17,5 +39,56 @@ func (s *AtomicStore) Load() *ContentStore {
 }
 
 func (s *AtomicStore) Store(cs *ContentStore) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.history = append(s.history, cs)
+	s.trim()
+	s.ptr.Store(cs)
+}
+
+func (s *AtomicStore) trim() {
+	if n := len(s.history) - s.limit; n > 0 {
+		clear(s.history[:n])
+		s.history = s.history[n:]
+	}
+}
+
+// History returns the kept snapshots, most recent first.
+func (s *AtomicStore) History() []*ContentStore {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h := make([]*ContentStore, len(s.history))
+	for i, cs := range s.history {
+		h[len(h)-1-i] = cs
+	}
+	return h
+}
+
+// Rollback makes an earlier snapshot current and drops every snapshot newer
+// than it. With an empty commit it goes back one snapshot; otherwise it goes
+// back to the most recent snapshot loaded from that commit.
+func (s *AtomicStore) Rollback(commit string) (*ContentStore, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := -1
+	if commit == "" {
+		i = len(s.history) - 2
+	} else {
+		for j := len(s.history) - 2; j >= 0; j-- {
+			if s.history[j].Commit == commit {
+				i = j
+				break
+			}
+		}
+	}
+	if i < 0 {
+		return nil, ErrNoSnapshot
+	}
+
+	cs := s.history[i]
+	clear(s.history[i+1:])
+	s.history = s.history[:i+1]
 	s.ptr.Store(cs)
+	return cs, nil
 }
@@ -0,0 +1,49 @@
+package content
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAtomicStore_Rollback(t *testing.T) {
+	s := NewAtomicStore()
+	s.SetHistoryLimit(3)
+	for _, commit := range []string{"a", "b", "c", "d"} {
+		s.Store(&ContentStore{Commit: commit})
+	}
+
+	commits := func() []string {
+		var out []string
+		for _, cs := range s.History() {
+			out = append(out, cs.Commit)
+		}
+		return out
+	}
+	if got := commits(); len(got) != 3 || got[0] != "d" || got[2] != "b" {
+		t.Fatalf("expected history [d c b], got %v", got)
+	}
+
+	if _, err := s.Rollback("a"); !errors.Is(err, ErrNoSnapshot) {
+		t.Errorf("expected ErrNoSnapshot for a dropped snapshot, got %v", err)
+	}
+
+	cs, err := s.Rollback("b")
+	if err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	if cs.Commit != "b" || s.Load().Commit != "b" {
+		t.Errorf("expected b to be current, got %q", s.Load().Commit)
+	}
+	if got := commits(); len(got) != 1 {
+		t.Errorf("expected newer snapshots to be dropped, got %v", got)
+	}
+
+	if _, err := s.Rollback(""); !errors.Is(err, ErrNoSnapshot) {
+		t.Errorf("expected ErrNoSnapshot with nothing older, got %v", err)
+	}
+
+	s.Store(&ContentStore{Commit: "e"})
+	if _, err := s.Rollback(""); err != nil || s.Load().Commit != "b" {
+		t.Errorf("expected rollback to the previous snapshot, got %q, %v", s.Load().Commit, err)
+	}
+}
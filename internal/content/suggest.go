@@ -0,0 +1,140 @@
+package content
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxCorrections is how many unknown words Suggest looks up, as each one
+// is compared with much of the vocabulary. Later ones are left as they are.
+const maxCorrections = 3
+
+// Suggest returns query with every word that matches nothing replaced by
+// the closest word in the index, for a "did you mean" link when a search
+// finds nothing. It returns "" if there is nothing to correct, or if query
+// is too long to search. Qualifiers other than title: are left alone.
+func (idx *SearchIndex) Suggest(query string) string {
+	if idx == nil || len(query) > maxQueryLen {
+		return ""
+	}
+
+	var out []string
+	changed := false
+	budget := maxCorrections
+	rest := strings.TrimSpace(query)
+	for rest != "" {
+		tok, remaining, err := nextQueryToken(rest)
+		if err != nil {
+			return ""
+		}
+		rest = strings.TrimLeftFunc(remaining, unicode.IsSpace)
+
+		key, _, qualified := strings.Cut(strings.TrimPrefix(tok, "-"), ":")
+		key = strings.ToLower(key)
+		if _, known := qualifierExample[key]; tok == "OR" || (qualified && known && key != "title") {
+			out = append(out, tok)
+			continue
+		}
+		fixed := idx.correctWords(tok, &budget)
+		changed = changed || fixed != tok
+		out = append(out, fixed)
+	}
+	if !changed {
+		return ""
+	}
+	return strings.Join(out, " ")
+}
+
+// correctWords replaces the unknown words in s, keeping everything
+// between them. It looks up at most *budget of them, counting them off.
+func (idx *SearchIndex) correctWords(s string, budget *int) string {
+	var b strings.Builder
+	for s != "" {
+		i := strings.IndexFunc(s, func(r rune) bool { return !isWordSeparator(r) })
+		if i < 0 {
+			b.WriteString(s)
+			break
+		}
+		b.WriteString(s[:i])
+		s = s[i:]
+		j := strings.IndexFunc(s, isWordSeparator)
+		if j < 0 {
+			j = len(s)
+		}
+		word := s[:j]
+		if lower := strings.ToLower(word); *budget > 0 && utf8.RuneCountInString(lower) >= minPrefix && !idx.known(lower) {
+			*budget--
+			if fix := idx.closestWord(lower); fix != "" {
+				word = fix
+			}
+		}
+		b.WriteString(word)
+		s = s[j:]
+	}
+	return b.String()
+}
+
+// closestWord returns the word in the index nearest to an unknown word,
+// or "" if nothing is near it. Ties go to the word in the most posts.
+func (idx *SearchIndex) closestWord(word string) string {
+	n := utf8.RuneCountInString(word)
+	maxDist := 1
+	if n > 5 {
+		maxDist = 2
+	}
+
+	// Words whose lengths differ by more than maxDist can't be near, so
+	// only those of nearby lengths are compared, shortest first to keep
+	// the choice between equally near words stable.
+	best, bestDist, bestFreq := "", maxDist+1, 0
+	for l := n - maxDist; l <= n+maxDist; l++ {
+		for _, w := range idx.byLen[l] {
+			d := editDistance(word, w)
+			freq := len(idx.postings[idx.stems[w]])
+			if d < bestDist || (d == bestDist && freq > bestFreq) {
+				best, bestDist, bestFreq = w, d, freq
+			}
+		}
+	}
+	return best
+}
+
+// known reports whether word, or a word it starts, is in the index.
+func (idx *SearchIndex) known(word string) bool {
+	if _, ok := idx.stems[word]; ok {
+		return true
+	}
+	if len(idx.postings[analyze(word)[0]]) > 0 {
+		return true
+	}
+	return len(idx.withPrefix(word)) > 0
+}
+
+// editDistance is the number of single-rune insertions, deletions,
+// substitutions and adjacent transpositions that turn a into b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	// Rows i-2, i-1 and i of the distance table.
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(rb)]
+}
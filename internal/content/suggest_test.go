@@ -0,0 +1,82 @@
+package content
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"seccomp", "seccomp", 0},
+		{"seccmop", "seccomp", 1}, // transposition
+		{"secomp", "seccomp", 1},
+		{"kuberntes", "kubernetes", 1},
+		{"kubernets", "kubernetes", 1},
+		{"cat", "dog", 3},
+		{"", "abc", 3},
+		{"naïve", "naive", 1},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSearchIndex_Suggest(t *testing.T) {
+	idx := NewSearchIndex([]BlogPost{
+		{Title: "Seccomp Filters", PlainText: "Sandboxing with seccomp on Kubernetes."},
+		{Title: "Kubernetes Networking", Tags: []string{"kubernetes"}},
+	})
+
+	tests := []struct {
+		query, want string
+	}{
+		{"seccmop", "seccomp"},
+		{"kuberntes -seccmop", "kubernetes -seccomp"},
+		{`"sandboxng with" tag:kubernets`, `"sandboxing with" tag:kubernets`},
+		{"title:kuberntes", "title:kubernetes"},
+		{"seccomp", ""},
+		{"kube", ""}, // a prefix of a known word
+		{"xyzzy", ""},
+		{"qz", ""},
+		{`"unclosed`, ""},
+		// Only the first few unknown words are looked up.
+		{"seccmop xyzzy kuberntes sandboxng", "seccomp xyzzy kubernetes sandboxng"},
+		{strings.Repeat("seccmop ", 40), ""},
+	}
+	for _, tt := range tests {
+		if got := idx.Suggest(tt.query); got != tt.want {
+			t.Errorf("Suggest(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestSearchIndex_PrefixMatching(t *testing.T) {
+	posts := []BlogPost{
+		{Slug: "k8s", Title: "Kubernetes Networking"},
+		{Slug: "kube", Title: "Kube Notes", PlainText: "Short for kube."},
+		{Slug: "google", Title: "Google Cloud"},
+	}
+	idx := NewSearchIndex(posts)
+
+	results := search(t, idx, "kube")
+	if len(results) != 2 || results[0].Post.Slug != "kube" {
+		t.Errorf("expected the exact match first, then the prefix match, got %d results", len(results))
+	}
+	if results := search(t, idx, "kubern"); len(results) != 1 || results[0].Post.Slug != "k8s" {
+		t.Errorf("expected the last word to match as a prefix")
+	}
+	if results := search(t, idx, "kubern networking"); len(results) != 0 {
+		t.Errorf("expected only the last word to match as a prefix, got %d results", len(results))
+	}
+	if results := search(t, idx, "go"); len(results) != 0 {
+		t.Errorf("expected no prefix matching for short words, got %d results", len(results))
+	}
+	if results := search(t, idx, `"kubern"`); len(results) != 0 {
+		t.Errorf("expected no prefix matching in quotes, got %d results", len(results))
+	}
+}
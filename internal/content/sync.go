@@ -2,102 +2,568 @@ package content
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
-	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+
+	"github.com/willfindlay/williamfindlaycom/internal/watch"
 )
 
 type SyncConfig struct {
-	RepoURL   string
-	Branch    string
+	RepoURL string // empty to serve Dir as-is, without git
+	Branch  string
+	// Ref pins the content to a tag, a full commit SHA, or the latest tag
+	// matching a glob such as "release-*". Empty tracks the head of Branch.
+	Ref       string
 	Dir       string
 	Interval  time.Duration
-	AuthToken string
-}
+	AuthToken string // for HTTP(S) remotes
 
-func auth(token string) *githttp.BasicAuth {
-	if token == "" {
-		return nil
-	}
-	return &githttp.BasicAuth{
-		Username: "git",
-		Password: token,
-	}
+	// SSHKey is a PEM-encoded private key for SSH remotes, such as a
+	// read-only deploy key. The server's host key is verified against
+	// KnownHostsFile, if set, or else the default known_hosts files, unless
+	// InsecureIgnoreHostKey is set.
+	SSHKey                []byte
+	SSHKeyPassphrase      string
+	KnownHostsFile        string
+	InsecureIgnoreHostKey bool
+
+	// TrustedKeys, if set, restricts syncs to commits signed by one of its
+	// keys. Unsigned or untrusted commits are never checked out.
+	TrustedKeys *TrustedKeys
+
+	// OnReload, if set, is called after each reload of the store.
+	OnReload func(*ContentStore)
 }
 
+// errCorruptRepo marks failures that mean the local clone can't be trusted
+// and has to be replaced, as opposed to e.g. the remote being unreachable.
+var errCorruptRepo = errors.New("corrupt content repo")
+
+// CloneOrPull brings cfg.Dir up to date with cfg.Branch on the remote. An
+// existing clone is fetched and hard-reset to the remote branch, so
+// force-pushes and local modifications don't wedge the sync. A missing or
+// corrupt clone is replaced with a fresh one.
 func CloneOrPull(cfg SyncConfig) error {
 	if _, err := os.Stat(filepath.Join(cfg.Dir, ".git")); err != nil {
 		if !os.IsNotExist(err) {
 			return fmt.Errorf("checking content dir: %w", err)
 		}
-		slog.Info("cloning content repo", "url", cfg.RepoURL, "branch", cfg.Branch)
-		_, err := git.PlainClone(cfg.Dir, false, &git.CloneOptions{
-			URL:           cfg.RepoURL,
-			ReferenceName: refName(cfg.Branch),
-			SingleBranch:  true,
-			Depth:         1,
-			Auth:          auth(cfg.AuthToken),
-		})
-		return err
+		return reclone(cfg)
 	}
 
+	err := fetchAndReset(cfg)
+	if errors.Is(err, errCorruptRepo) {
+		slog.Warn("content repo is corrupt, re-cloning", "err", err)
+		return reclone(cfg)
+	}
+	return err
+}
+
+func fetchAndReset(cfg SyncConfig) error {
 	repo, err := git.PlainOpen(cfg.Dir)
 	if err != nil {
-		return fmt.Errorf("opening repo: %w", err)
+		return fmt.Errorf("%w: opening repo: %w", errCorruptRepo, err)
+	}
+	// Check the clone is intact up front, so that a fetch failure can be
+	// blamed on the remote rather than on local state.
+	if _, err := repo.Config(); err != nil {
+		return fmt.Errorf("%w: reading config: %w", errCorruptRepo, err)
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return fmt.Errorf("%w: reading HEAD: %w", errCorruptRepo, err)
+	}
+	if _, err := repo.CommitObject(head.Hash()); err != nil {
+		return fmt.Errorf("%w: reading HEAD commit: %w", errCorruptRepo, err)
+	}
+
+	slog.Info("fetching content repo", "ref", cfg.target())
+	return checkout(repo, cfg)
+}
+
+// checkout fetches the commit cfg points at and hard-resets the worktree
+// to it, discarding any local changes.
+func checkout(repo *git.Repository, cfg SyncConfig) error {
+	src, err := resolveSource(cfg)
+	if err != nil {
+		return err
+	}
+	auth, err := cfg.auth()
+	if err != nil {
+		return err
+	}
+	err = repo.Fetch(&git.FetchOptions{
+		RemoteURL: cfg.RepoURL,
+		RefSpecs:  []config.RefSpec{config.RefSpec(fmt.Sprintf("+%s:%s", src, fetchedRef))},
+		Depth:     1,
+		Auth:      auth,
+		Tags:      git.NoTags,
+		Force:     true,
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("fetching %s: %w", src, err)
+	}
+
+	ref, err := repo.Reference(fetchedRef, true)
+	if err != nil {
+		return fmt.Errorf("%w: resolving %s: %w", errCorruptRepo, fetchedRef, err)
+	}
+	hash := ref.Hash()
+	if tag, err := repo.TagObject(hash); err == nil {
+		commit, err := tag.Commit()
+		if err != nil {
+			return fmt.Errorf("%w: peeling tag %s: %w", errCorruptRepo, tag.Name, err)
+		}
+		hash = commit.Hash
+	}
+
+	if cfg.TrustedKeys != nil {
+		commit, err := repo.CommitObject(hash)
+		if err != nil {
+			return fmt.Errorf("%w: reading %s: %w", errCorruptRepo, hash, err)
+		}
+		if err := cfg.TrustedKeys.Verify(commit); err != nil {
+			slog.Error("content commit rejected", "commit", hash, "err", err)
+			return err
+		}
+	}
+
+	// A fresh clone has an unborn HEAD, which Reset can't move; point the
+	// branch at the commit first.
+	if _, err := repo.Head(); errors.Is(err, plumbing.ErrReferenceNotFound) {
+		if err := repo.Storer.SetReference(plumbing.NewHashReference(refName(cfg.Branch), hash)); err != nil {
+			return fmt.Errorf("setting %s: %w", cfg.Branch, err)
+		}
 	}
 
 	w, err := repo.Worktree()
 	if err != nil {
-		return fmt.Errorf("worktree: %w", err)
+		return fmt.Errorf("%w: worktree: %w", errCorruptRepo, err)
+	}
+	if err := w.Reset(&git.ResetOptions{Commit: hash, Mode: git.HardReset}); err != nil {
+		return fmt.Errorf("%w: resetting to %s: %w", errCorruptRepo, hash, err)
+	}
+	if err := w.Clean(&git.CleanOptions{Dir: true}); err != nil {
+		return fmt.Errorf("%w: cleaning worktree: %w", errCorruptRepo, err)
+	}
+	return nil
+}
+
+// reclone clones into a temporary directory inside cfg.Dir and swaps it
+// into place only once the clone is complete, so a failed or interrupted
+// clone never replaces working content. Cloning inside cfg.Dir keeps the
+// clone on the same filesystem even when cfg.Dir is a mount point.
+func reclone(cfg SyncConfig) (err error) {
+	if _, err := os.Stat(cfg.Dir); os.IsNotExist(err) {
+		// Don't leave an empty content dir behind if the clone fails.
+		defer func() {
+			if err != nil {
+				os.Remove(cfg.Dir) //nolint:errcheck
+			}
+		}()
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return fmt.Errorf("creating content dir: %w", err)
+	}
+	removeStale(cfg.Dir)
+	tmp, err := os.MkdirTemp(cfg.Dir, cloneDirPrefix)
+	if err != nil {
+		return fmt.Errorf("creating clone dir: %w", err)
+	}
+
+	slog.Info("cloning content repo", "url", cfg.RepoURL, "ref", cfg.target())
+	if err := cloneInto(tmp, cfg); err != nil {
+		os.RemoveAll(tmp) //nolint:errcheck
+		return fmt.Errorf("cloning: %w", err)
+	}
+
+	if err := swapDir(tmp, cfg.Dir); err != nil {
+		os.RemoveAll(tmp) //nolint:errcheck
+		return err
 	}
+	return nil
+}
+
+// Prefixes of the directories reclone and swapDir make inside the content
+// dir, which an interrupted sync can leave behind.
+const (
+	cloneDirPrefix = ".clone-"
+	oldDirPrefix   = ".old-"
+)
 
-	slog.Info("pulling content repo")
-	err = w.Pull(&git.PullOptions{
-		ReferenceName: refName(cfg.Branch),
-		SingleBranch:  true,
-		Auth:          auth(cfg.AuthToken),
+// removeStale removes clones and old content left in dir by an earlier
+// sync that was interrupted.
+func removeStale(dir string) {
+	for _, prefix := range []string{cloneDirPrefix, oldDirPrefix} {
+		stale, _ := filepath.Glob(filepath.Join(dir, prefix+"*"))
+		for _, p := range stale {
+			if err := os.RemoveAll(p); err != nil {
+				slog.Warn("removing stale clone", "dir", p, "err", err)
+			}
+		}
+	}
+}
+
+func cloneInto(dir string, cfg SyncConfig) error {
+	repo, err := git.PlainInitWithOptions(dir, &git.PlainInitOptions{
+		InitOptions: git.InitOptions{DefaultBranch: refName(cfg.Branch)},
 	})
-	if err == git.NoErrAlreadyUpToDate {
-		slog.Info("content already up to date")
-		return nil
+	if err != nil {
+		return err
 	}
-	return err
+	_, err = repo.CreateRemote(&config.RemoteConfig{
+		Name: git.DefaultRemoteName,
+		URLs: []string{cfg.RepoURL},
+	})
+	if err != nil {
+		return err
+	}
+	return checkout(repo, cfg)
 }
 
-func StartBackgroundSync(ctx context.Context, cfg SyncConfig, store *AtomicStore) {
-	ticker := time.NewTicker(cfg.Interval)
-	defer ticker.Stop()
+// swapDir replaces the contents of dst with src, a directory inside it.
+// It renames dst aside and src into its place, or, if dst can't be
+// renamed because it is a mount point, swaps their entries instead.
+func swapDir(src, dst string) error {
+	parent, base := filepath.Split(filepath.Clean(dst))
+	old := filepath.Join(parent, "."+base+oldDirPrefix+strings.TrimPrefix(filepath.Base(src), cloneDirPrefix))
+	if err := os.Rename(dst, old); err != nil {
+		if errors.Is(err, syscall.EBUSY) || errors.Is(err, syscall.EXDEV) {
+			return swapEntries(src, dst)
+		}
+		return fmt.Errorf("moving old content dir aside: %w", err)
+	}
+	if err := os.Rename(filepath.Join(old, filepath.Base(src)), dst); err != nil {
+		os.Rename(old, dst) //nolint:errcheck
+		return fmt.Errorf("moving new clone into place: %w", err)
+	}
+	if err := os.RemoveAll(old); err != nil {
+		slog.Warn("removing old content dir", "dir", old, "err", err)
+	}
+	return nil
+}
+
+// swapEntries replaces the contents of dir with those of src, a directory
+// inside it. Unlike swapDir's rename this isn't atomic, so if a move fails
+// part way, everything moved so far is moved back.
+func swapEntries(src, dir string) error {
+	old, err := os.MkdirTemp(dir, oldDirPrefix)
+	if err != nil {
+		return fmt.Errorf("creating dir for old content: %w", err)
+	}
+	skip := map[string]bool{filepath.Base(src): true, filepath.Base(old): true}
+
+	if err := moveEntries(dir, old, skip); err != nil {
+		moveEntries(old, dir, nil) //nolint:errcheck
+		os.Remove(old)             //nolint:errcheck
+		return fmt.Errorf("moving old content aside: %w", err)
+	}
+	if err := moveEntries(src, dir, nil); err != nil {
+		moveEntries(dir, src, skip) //nolint:errcheck
+		moveEntries(old, dir, nil)  //nolint:errcheck
+		os.Remove(old)              //nolint:errcheck
+		return fmt.Errorf("moving new clone into place: %w", err)
+	}
+
+	if err := os.Remove(src); err != nil {
+		slog.Warn("removing clone dir", "dir", src, "err", err)
+	}
+	if err := os.RemoveAll(old); err != nil {
+		slog.Warn("removing old content", "dir", old, "err", err)
+	}
+	return nil
+}
+
+// moveEntries moves the entries of from, except those named in skip, into
+// to, stopping at the first that fails.
+func moveEntries(from, to string, skip map[string]bool) error {
+	entries, err := os.ReadDir(from)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if skip[e.Name()] {
+			continue
+		}
+		if err := os.Rename(filepath.Join(from, e.Name()), filepath.Join(to, e.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// watchInterval is how often a local content directory is polled for
+// changes when there is no git remote to sync from.
+var watchInterval = 500 * time.Millisecond
+
+// Syncer keeps an AtomicStore up to date with the content source. With a
+// repo URL it pulls every cfg.Interval or when triggered; without one,
+// cfg.Dir is served as-is and reloaded whenever a file in it changes.
+type Syncer struct {
+	cfg     SyncConfig
+	store   *AtomicStore
+	trigger chan struct{}
+
+	mu     sync.Mutex
+	status SyncStatus
+	loaded *ContentStore // last store loaded from disk
+}
+
+// SyncStatus describes the outcome of recent syncs.
+type SyncStatus struct {
+	LastAttempt time.Time
+	LastSuccess time.Time
+	LastError   string // empty if the last attempt succeeded
+
+	// LastErrorStage is the stage the last attempt failed at, one of
+	// StageFetch, StageVerify or StageLoad, for reporting the failure
+	// without the details LastError may have.
+	LastErrorStage string
+}
+
+// Stages a sync can fail at.
+const (
+	StageFetch  = "fetch"  // pulling the repo or reading its HEAD
+	StageVerify = "verify" // checking the commit's signature
+	StageLoad   = "load"   // loading content from disk
+)
+
+// stageError is a sync error with the stage it happened at.
+type stageError struct {
+	stage string
+	err   error
+}
+
+func (e *stageError) Error() string { return e.err.Error() }
+func (e *stageError) Unwrap() error { return e.err }
+
+// errorStage returns the stage err happened at.
+func errorStage(err error) string {
+	if errors.Is(err, ErrUntrustedCommit) {
+		return StageVerify
+	}
+	var se *stageError
+	if errors.As(err, &se) {
+		return se.stage
+	}
+	return StageLoad
+}
+
+func NewSyncer(cfg SyncConfig, store *AtomicStore) *Syncer {
+	return &Syncer{
+		cfg:     cfg,
+		store:   store,
+		trigger: make(chan struct{}, 1),
+	}
+}
+
+// Trigger requests a sync as soon as possible. Triggers that arrive while
+// one is already pending are coalesced into it.
+func (s *Syncer) Trigger() {
+	select {
+	case s.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Run syncs until ctx is done.
+func (s *Syncer) Run(ctx context.Context) {
+	var tick <-chan time.Time
+	if s.cfg.RepoURL == "" {
+		go watch.Poll(ctx, watchInterval, s.Trigger, s.cfg.Dir)
+	} else {
+		ticker := time.NewTicker(s.cfg.Interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	// publish fires when the earliest scheduled item becomes due, so it goes
+	// live on time even if no new commit arrives in the meantime.
+	var publish *time.Timer
+	// failed is the NextPublish a reload failed for. A past date would set
+	// off the timer at once, so it isn't retried until the next sync.
+	var failed time.Time
+	defer func() {
+		if publish != nil {
+			publish.Stop()
+		}
+	}()
 
 	for {
+		if publish != nil {
+			publish.Stop()
+			publish = nil
+		}
+		// A rolled-back store stays as it is until a new commit arrives, so
+		// its scheduled items wait for that too: reloading would serve
+		// what is on disk, undoing the rollback.
+		var due <-chan time.Time
+		if cs := s.store.Load(); cs != nil && !cs.NextPublish.IsZero() && !cs.NextPublish.Equal(failed) && !s.rolledBack() {
+			publish = time.NewTimer(time.Until(cs.NextPublish))
+			due = publish.C
+		}
+
 		select {
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
-			if err := CloneOrPull(cfg); err != nil {
-				slog.Error("content sync failed", "err", err)
+		case <-due:
+			if s.rolledBack() {
+				// Rolled back since the timer was set.
 				continue
 			}
-
-			cs, err := LoadFromDir(cfg.Dir)
-			if err != nil {
+			slog.Info("publishing scheduled content")
+			next := s.store.Load().NextPublish
+			if err := s.record(s.reload()); err != nil {
 				slog.Error("content reload failed", "err", err)
-				continue
+				failed = next
+			}
+		case <-s.trigger:
+			failed = time.Time{}
+			if err := s.Sync(); err != nil {
+				slog.Error("content sync failed", "err", err)
+			}
+		case <-tick:
+			failed = time.Time{}
+			if err := s.Sync(); err != nil {
+				slog.Error("content sync failed", "err", err)
 			}
+		}
+	}
+}
+
+// Sync brings the store up to date: it pulls the repo, if there is one,
+// and reloads content from disk. The outcome is recorded in Status.
+func (s *Syncer) Sync() error {
+	return s.record(s.pullAndReload())
+}
+
+// Status reports the outcome of recent syncs.
+func (s *Syncer) Status() SyncStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+func (s *Syncer) loadedCommit() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.loaded == nil {
+		return ""
+	}
+	return s.loaded.Commit
+}
+
+// rolledBack reports whether the store serves an earlier snapshot than the
+// one last loaded from disk.
+func (s *Syncer) rolledBack() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loaded != nil && s.store.Load() != s.loaded
+}
+
+func (s *Syncer) record(err error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.LastAttempt = time.Now()
+	if err != nil {
+		s.status.LastError = err.Error()
+		s.status.LastErrorStage = errorStage(err)
+	} else {
+		s.status.LastSuccess = s.status.LastAttempt
+		s.status.LastError = ""
+		s.status.LastErrorStage = ""
+	}
+	return err
+}
+
+func (s *Syncer) pullAndReload() error {
+	if s.cfg.RepoURL == "" {
+		info, err := os.Stat(s.cfg.Dir)
+		if err != nil {
+			return fmt.Errorf("local content dir: %w", err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("local content dir %s is not a directory", s.cfg.Dir)
+		}
+	} else {
+		if err := CloneOrPull(s.cfg); err != nil {
+			return &stageError{StageFetch, fmt.Errorf("pulling: %w", err)}
+		}
+		// Only reload when the commit moved, which also keeps a rolled-back
+		// store in place until there is something new to serve.
+		commit, _, err := headCommit(s.cfg.Dir)
+		if err != nil {
+			return &stageError{StageFetch, fmt.Errorf("reading HEAD: %w", err)}
+		}
+		if commit == s.loadedCommit() {
+			slog.Info("content already up to date", "commit", commit)
+			return nil
+		}
+	}
+
+	if err := s.reload(); err != nil {
+		return fmt.Errorf("loading: %w", err)
+	}
+	return nil
+}
+
+func (s *Syncer) reload() error {
+	cs, err := Reload(s.cfg.Dir, s.store.Load())
+	if err != nil {
+		return err
+	}
+	for _, e := range cs.LoadErrors {
+		slog.Warn("content file skipped", "file", e.File, "err", e.Err)
+	}
 
-			store.Store(cs)
-			slog.Info("content reloaded",
-				"posts", len(cs.Posts),
-				"projects", len(cs.Projects),
-				"redirects", len(cs.Redirects),
-			)
+	if s.cfg.RepoURL != "" {
+		cs.Commit, cs.CommitTime, err = headCommit(s.cfg.Dir)
+		if err != nil {
+			return fmt.Errorf("reading HEAD: %w", err)
 		}
 	}
+
+	s.store.Store(cs)
+	s.mu.Lock()
+	s.loaded = cs
+	s.mu.Unlock()
+	slog.Info("content reloaded",
+		"posts", len(cs.Posts),
+		"load_errors", len(cs.LoadErrors),
+		"projects", len(cs.Projects),
+		"redirects", len(cs.Redirects),
+	)
+	if s.cfg.OnReload != nil {
+		s.cfg.OnReload(cs)
+	}
+	return nil
+}
+
+func headCommit(dir string) (string, time.Time, error) {
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return commit.Hash.String(), commit.Committer.When, nil
 }
 
 func refName(branch string) plumbing.ReferenceName {
@@ -0,0 +1,458 @@
+package content
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// waitFor polls cond until it returns true or the deadline passes.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSyncer_LocalDir(t *testing.T) {
+	orig := watchInterval
+	watchInterval = 10 * time.Millisecond
+	defer func() { watchInterval = orig }()
+
+	dir := t.TempDir()
+	blogDir := filepath.Join(dir, "blog")
+	if err := os.MkdirAll(blogDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writePost := func(name, title string) {
+		t.Helper()
+		src := "---\ntitle: " + title + "\ndate: 2024-01-01\n---\n\nBody.\n"
+		if err := os.WriteFile(filepath.Join(blogDir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writePost("first.md", "First")
+
+	cs, err := LoadFromDir(dir)
+	if err != nil {
+		t.Fatalf("LoadFromDir: %v", err)
+	}
+	store := NewAtomicStore()
+	store.Store(cs)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go NewSyncer(SyncConfig{Dir: dir}, store).Run(ctx)
+
+	time.Sleep(30 * time.Millisecond)
+	writePost("second.md", "Second")
+	waitFor(t, "new post to load", func() bool {
+		return len(store.Load().Posts) == 2
+	})
+
+	writePost("first.md", "First, edited")
+	waitFor(t, "edited post to reload", func() bool {
+		p, ok := store.Load().PostsBySlug["first"]
+		return ok && p.Title == "First, edited"
+	})
+}
+
+func TestSyncer_TriggerCoalesces(t *testing.T) {
+	s := NewSyncer(SyncConfig{}, NewAtomicStore())
+	for range 5 {
+		s.Trigger()
+	}
+	if n := len(s.trigger); n != 1 {
+		t.Errorf("expected triggers to coalesce into 1 pending sync, got %d", n)
+	}
+}
+
+// newRemote creates a git repo to sync from, with a single post committed
+// on main.
+func newRemote(t *testing.T) (string, *git.Repository) {
+	t.Helper()
+	dir := t.TempDir()
+	repo, err := git.PlainInitWithOptions(dir, &git.PlainInitOptions{
+		InitOptions: git.InitOptions{DefaultBranch: refName("main")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitPost(t, repo, "first.md", "First")
+	return dir, repo
+}
+
+// commitPost writes a blog post into the remote's worktree and commits it.
+func commitPost(t *testing.T, repo *git.Repository, name, title string) plumbing.Hash {
+	t.Helper()
+	return commitPostWith(t, repo, name, title, &git.CommitOptions{})
+}
+
+func commitPostWith(t *testing.T, repo *git.Repository, name, title string, opts *git.CommitOptions) plumbing.Hash {
+	t.Helper()
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	blogDir := filepath.Join(w.Filesystem.Root(), "blog")
+	if err := os.MkdirAll(blogDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	src := "---\ntitle: " + title + "\ndate: 2024-01-01\n---\n\nBody.\n"
+	if err := os.WriteFile(filepath.Join(blogDir, name), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Add("blog/" + name); err != nil {
+		t.Fatal(err)
+	}
+	opts.Author = &object.Signature{Name: "Test", Email: "test@example.com", When: time.Now()}
+	hash, err := w.Commit("add "+name, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func postTitles(t *testing.T, store *AtomicStore) []string {
+	t.Helper()
+	var titles []string
+	for _, p := range store.Load().Posts {
+		titles = append(titles, p.Title)
+	}
+	sort.Strings(titles)
+	return titles
+}
+
+func TestSyncer_Git(t *testing.T) {
+	remoteDir, remote := newRemote(t)
+	initial, err := remote.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := SyncConfig{
+		RepoURL: remoteDir,
+		Branch:  "main",
+		Dir:     filepath.Join(t.TempDir(), "content"),
+	}
+	store := NewAtomicStore()
+	s := NewSyncer(cfg, store)
+
+	sync := func(t *testing.T, want ...string) {
+		t.Helper()
+		if err := s.Sync(); err != nil {
+			t.Fatalf("Sync: %v", err)
+		}
+		if got := postTitles(t, store); !slices.Equal(got, want) {
+			t.Fatalf("expected posts %v, got %v", want, got)
+		}
+	}
+
+	sync(t, "First")
+
+	head := commitPost(t, remote, "second.md", "Second")
+	sync(t, "First", "Second")
+	if got := store.Load().Commit; got != head.String() {
+		t.Errorf("expected commit %s, got %s", head, got)
+	}
+
+	t.Run("force push", func(t *testing.T) {
+		w, err := remote.Worktree()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Reset(&git.ResetOptions{Commit: initial.Hash(), Mode: git.HardReset}); err != nil {
+			t.Fatal(err)
+		}
+		commitPost(t, remote, "rewritten.md", "Rewritten")
+		sync(t, "First", "Rewritten")
+	})
+
+	t.Run("dirty worktree", func(t *testing.T) {
+		if err := os.WriteFile(filepath.Join(cfg.Dir, "blog", "first.md"), []byte("garbage"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		src := "---\ntitle: Stray\ndate: 2024-01-01\n---\n"
+		if err := os.WriteFile(filepath.Join(cfg.Dir, "blog", "stray.md"), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		sync(t, "First", "Rewritten")
+	})
+
+	t.Run("corrupt clone", func(t *testing.T) {
+		if err := os.RemoveAll(filepath.Join(cfg.Dir, ".git", "objects")); err != nil {
+			t.Fatal(err)
+		}
+		commitPost(t, remote, "third.md", "Third")
+		sync(t, "First", "Rewritten", "Third")
+
+		entries, err := os.ReadDir(filepath.Dir(cfg.Dir))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 {
+			t.Errorf("expected only the content dir to remain, got %d entries", len(entries))
+		}
+		for _, prefix := range []string{cloneDirPrefix, oldDirPrefix} {
+			if stale, _ := filepath.Glob(filepath.Join(cfg.Dir, prefix+"*")); len(stale) > 0 {
+				t.Errorf("expected no leftover dirs in the content dir, got %v", stale)
+			}
+		}
+	})
+
+	t.Run("unreachable remote", func(t *testing.T) {
+		broken := cfg
+		broken.RepoURL = filepath.Join(t.TempDir(), "missing")
+		if err := NewSyncer(broken, store).Sync(); err == nil {
+			t.Fatal("expected sync from a missing remote to fail")
+		}
+		if _, err := os.Stat(filepath.Join(cfg.Dir, "blog", "third.md")); err != nil {
+			t.Errorf("expected existing clone to be left alone: %v", err)
+		}
+		if got := postTitles(t, store); len(got) != 3 {
+			t.Errorf("expected previous content to stay loaded, got %v", got)
+		}
+	})
+}
+
+// TestSwapEntries covers the swap used when the content dir is a mount
+// point and can't be renamed.
+func TestSwapEntries(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(dir, "blog", "old.md"), "old")
+	write(filepath.Join(dir, ".git", "HEAD"), "old")
+	src := filepath.Join(dir, cloneDirPrefix+"1")
+	write(filepath.Join(src, "blog", "new.md"), "new")
+	write(filepath.Join(src, ".git", "HEAD"), "new")
+
+	if err := swapEntries(src, dir); err != nil {
+		t.Fatalf("swapEntries: %v", err)
+	}
+
+	var files []string
+	err := filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			rel, _ := filepath.Rel(dir, p)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{".git/HEAD", "blog/new.md"}; !slices.Equal(files, want) {
+		t.Errorf("expected %v after the swap, got %v", want, files)
+	}
+	if head, _ := os.ReadFile(filepath.Join(dir, ".git", "HEAD")); string(head) != "new" {
+		t.Errorf("expected the new .git, got HEAD %q", head)
+	}
+}
+
+func TestSyncer_RollbackHoldsScheduledPublish(t *testing.T) {
+	remoteDir, remote := newRemote(t)
+	cfg := SyncConfig{
+		RepoURL:  remoteDir,
+		Branch:   "main",
+		Dir:      filepath.Join(t.TempDir(), "content"),
+		Interval: time.Hour,
+	}
+	store := NewAtomicStore()
+	s := NewSyncer(cfg, store)
+	if err := s.Sync(); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	good := store.Load()
+
+	commitPost(t, remote, "second.md", "Bad")
+	if err := s.Sync(); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+
+	// The snapshot rolled back to has a scheduled post that is already due.
+	good.NextPublish = time.Now().Add(-time.Minute)
+	if _, err := store.Rollback(good.Commit); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	time.Sleep(100 * time.Millisecond)
+	if got := store.Load(); got != good {
+		t.Errorf("expected the rollback to hold, got commit %s with posts %v", got.Commit, postTitles(t, store))
+	}
+}
+
+func TestSyncer_FailedPublishDoesNotSpin(t *testing.T) {
+	// Loading from a file rather than a directory always fails.
+	dir := filepath.Join(t.TempDir(), "content")
+	if err := os.WriteFile(dir, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store := NewAtomicStore()
+	store.Store(&ContentStore{NextPublish: time.Now().Add(-time.Minute)})
+	s := NewSyncer(SyncConfig{RepoURL: "unused", Dir: dir, Interval: time.Hour}, store)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	waitFor(t, "the scheduled reload to fail", func() bool {
+		return s.Status().LastError != ""
+	})
+	first := s.Status().LastAttempt
+	time.Sleep(100 * time.Millisecond)
+	if got := s.Status().LastAttempt; !got.Equal(first) {
+		t.Errorf("expected no retry before the next sync, last attempt moved from %v to %v", first, got)
+	}
+}
+
+func TestSyncer_GitRef(t *testing.T) {
+	remoteDir, remote := newRemote(t)
+
+	// Let the pinned-commit case fetch a commit that isn't a branch tip.
+	rcfg, err := remote.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rcfg.Raw.Section("uploadpack").SetOption("allowReachableSHA1InWant", "true")
+	if err := remote.SetConfig(rcfg); err != nil {
+		t.Fatal(err)
+	}
+
+	tag := func(name string, hash plumbing.Hash, annotated bool) {
+		t.Helper()
+		var opts *git.CreateTagOptions
+		if annotated {
+			opts = &git.CreateTagOptions{
+				Message: name,
+				Tagger:  &object.Signature{Name: "Test", Email: "test@example.com", When: time.Now()},
+			}
+		}
+		if _, err := remote.CreateTag(name, hash, opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	head, err := remote.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := head.Hash()
+	tag("release-9", first, false)
+	second := commitPost(t, remote, "second.md", "Second")
+	tag("release-10", second, true)
+	third := commitPost(t, remote, "third.md", "Third")
+	tag("other", third, false)
+
+	tests := []struct {
+		ref  string
+		want plumbing.Hash
+	}{
+		{"", third},
+		{"release-9", first},
+		{"release-10", second},
+		{"release-*", second},
+		{first.String(), first},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			store := NewAtomicStore()
+			s := NewSyncer(SyncConfig{
+				RepoURL: remoteDir,
+				Branch:  "main",
+				Ref:     tt.ref,
+				Dir:     filepath.Join(t.TempDir(), "content"),
+			}, store)
+			if err := s.Sync(); err != nil {
+				t.Fatalf("Sync: %v", err)
+			}
+			if got := store.Load().Commit; got != tt.want.String() {
+				t.Errorf("expected commit %s, got %s", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("rollback survives unchanged syncs", func(t *testing.T) {
+		store := NewAtomicStore()
+		s := NewSyncer(SyncConfig{
+			RepoURL: remoteDir,
+			Branch:  "main",
+			Ref:     "release-*",
+			Dir:     filepath.Join(t.TempDir(), "content"),
+		}, store)
+		if err := s.Sync(); err != nil {
+			t.Fatal(err)
+		}
+		// Stands in for a snapshot rolled back to by an admin.
+		store.Store(&ContentStore{Commit: "rolled-back"})
+
+		if err := s.Sync(); err != nil {
+			t.Fatal(err)
+		}
+		if got := store.Load().Commit; got != "rolled-back" {
+			t.Errorf("expected an unchanged commit not to reload, got %s", got)
+		}
+
+		tag("release-11", third, false)
+		if err := s.Sync(); err != nil {
+			t.Fatal(err)
+		}
+		if got := store.Load().Commit; got != third.String() {
+			t.Errorf("expected new release %s to load, got %s", third, got)
+		}
+	})
+}
+
+func TestSyncConfig_Tracks(t *testing.T) {
+	tests := []struct {
+		ref   string
+		push  string
+		wants bool
+	}{
+		{"", "refs/heads/main", true},
+		{"", "refs/heads/feature", false},
+		{"v1", "refs/tags/v1", true},
+		{"v1", "refs/heads/main", false},
+		{"release-*", "refs/tags/release-3", true},
+		{"release-*", "refs/tags/v3", false},
+		{"0123456789abcdef0123456789abcdef01234567", "refs/heads/main", false},
+	}
+	for _, tt := range tests {
+		cfg := SyncConfig{Branch: "main", Ref: tt.ref}
+		if got := cfg.Tracks(tt.push); got != tt.wants {
+			t.Errorf("Ref %q, push %s: got %v, want %v", tt.ref, tt.push, got, tt.wants)
+		}
+	}
+}
+
+func TestCompareNatural(t *testing.T) {
+	tags := []string{"release-10", "release-9", "release-2024-01-02", "release-1.10", "release-1.9"}
+	slices.SortFunc(tags, compareNatural)
+	want := []string{"release-1.9", "release-1.10", "release-9", "release-10", "release-2024-01-02"}
+	if !slices.Equal(tags, want) {
+		t.Errorf("got %v, want %v", tags, want)
+	}
+}
@@ -0,0 +1,134 @@
+package content
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// tagsFile is the optional taxonomy in the content repo. It maps each tag
+// to its metadata:
+//
+//	ebpf:
+//	  name: eBPF
+//	  description: Posts about extending the kernel with eBPF.
+//	  aliases: [bpf]
+const tagsFile = "tags.yaml"
+
+// TagInfo describes a tag.
+type TagInfo struct {
+	Slug        string   `yaml:"-"`
+	Name        string   `yaml:"name"` // display name; defaults to the slug
+	Description string   `yaml:"description"`
+	Aliases     []string `yaml:"aliases"`
+}
+
+// parseTags parses tags.yaml, returning the tags by slug and a lookup from
+// every folded spelling of a tag (its slug, name and aliases) to its slug.
+func parseTags(data []byte) (map[string]TagInfo, map[string]string, error) {
+	var tags map[string]TagInfo
+	if err := yaml.Unmarshal(data, &tags); err != nil {
+		return nil, nil, yamlErrors(0, err)
+	}
+
+	// Tags are visited in a fixed order so clashes are reported the same
+	// way every time.
+	slugs := make([]string, 0, len(tags))
+	for slug := range tags {
+		slugs = append(slugs, slug)
+	}
+	sort.Strings(slugs)
+
+	aliases := make(map[string]string)
+	for _, slug := range slugs {
+		if foldTag(slug) == "" {
+			return nil, nil, fmt.Errorf("tag %q: slug has no letters or digits", slug)
+		}
+		t := tags[slug]
+		t.Slug = slug
+		if t.Name == "" {
+			t.Name = slug
+		}
+		tags[slug] = t
+
+		for _, spelling := range append([]string{slug, t.Name}, t.Aliases...) {
+			key := foldTag(spelling)
+			if other, ok := aliases[key]; ok && other != slug {
+				return nil, nil, fmt.Errorf("tag %q: %q is already used by tag %q", slug, spelling, other)
+			}
+			aliases[key] = slug
+		}
+	}
+	return tags, aliases, nil
+}
+
+func (l *loader) loadTags() error {
+	data, err := os.ReadFile(filepath.Join(l.dir, tagsFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return l.failTags(err)
+	}
+
+	tags, aliases, err := parseTags(data)
+	if err != nil {
+		return l.failTags(err)
+	}
+	l.store.Tags = tags
+	l.store.tagAliases = aliases
+	return nil
+}
+
+// failTags keeps the previous taxonomy if the file is bad, so tags aren't
+// split back into their variant spellings.
+func (l *loader) failTags(err error) error {
+	if err := l.fail(tagsFile, err); err != nil {
+		return err
+	}
+	if l.prev != nil {
+		maps.Copy(l.store.Tags, l.prev.Tags)
+		maps.Copy(l.store.tagAliases, l.prev.tagAliases)
+	}
+	return nil
+}
+
+// CanonicalTag returns the slug of the tag in the taxonomy that tag is a
+// spelling of, or tag itself if it isn't in the taxonomy.
+func (cs *ContentStore) CanonicalTag(tag string) string {
+	if slug, ok := cs.tagAliases[foldTag(tag)]; ok {
+		return slug
+	}
+	return tag
+}
+
+// TagInfo returns the metadata of a tag. Tags missing from the taxonomy
+// are named by their slug.
+func (cs *ContentStore) TagInfo(slug string) TagInfo {
+	if t, ok := cs.Tags[slug]; ok {
+		return t
+	}
+	return TagInfo{Slug: slug, Name: slug}
+}
+
+// normalizeTags maps tags to their canonical slugs, dropping duplicates.
+// It returns a new slice, as tags may be shared with a cached post.
+func (cs *ContentStore) normalizeTags(tags []string) []string {
+	if len(tags) == 0 {
+		return tags
+	}
+	out := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, t := range tags {
+		t = cs.CanonicalTag(t)
+		if !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	return out
+}
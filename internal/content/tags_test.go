@@ -0,0 +1,91 @@
+package content
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+const testTagsYAML = `ebpf:
+  name: eBPF
+  description: Extending the kernel.
+  aliases: [bpf]
+go:
+  aliases: [golang]
+`
+
+func TestParseTags(t *testing.T) {
+	tags, aliases, err := parseTags([]byte(testTagsYAML))
+	if err != nil {
+		t.Fatalf("parseTags: %v", err)
+	}
+	if got := tags["ebpf"]; got.Slug != "ebpf" || got.Name != "eBPF" || got.Description != "Extending the kernel." {
+		t.Errorf("unexpected ebpf tag: %+v", got)
+	}
+	if got := tags["go"].Name; got != "go" {
+		t.Errorf("expected the name to default to the slug, got %q", got)
+	}
+	for spelling, want := range map[string]string{"ebpf": "ebpf", "E-BPF": "ebpf", "BPF": "ebpf", "golang": "go"} {
+		if got := aliases[foldTag(spelling)]; got != want {
+			t.Errorf("%q resolves to %q, want %q", spelling, got, want)
+		}
+	}
+
+	_, _, err = parseTags([]byte("ebpf:\n  aliases: [bpf]\nbpf-maps:\n  name: BPF\n"))
+	if err == nil || !strings.Contains(err.Error(), `"bpf" is already used by tag "bpf-maps"`) {
+		t.Errorf("expected a clash error, got %v", err)
+	}
+}
+
+func TestLoadFromDir_TagTaxonomy(t *testing.T) {
+	dir := t.TempDir()
+	writeContent(t, dir, map[string]string{
+		"tags.yaml":   testTagsYAML,
+		"blog/one.md": "---\ntitle: One\ndate: 2024-01-01\ntags: [eBPF, Go]\n---\n",
+		"blog/two.md": "---\ntitle: Two\ndate: 2024-01-02\ntags: [bpf, ebpf, rust]\n---\n",
+	})
+
+	store, err := LoadFromDir(dir)
+	if err != nil {
+		t.Fatalf("LoadFromDir: %v", err)
+	}
+	if got := store.PostsBySlug["one"].Tags; !slices.Equal(got, []string{"ebpf", "go"}) {
+		t.Errorf("one tags = %v", got)
+	}
+	if got := store.PostsBySlug["two"].Tags; !slices.Equal(got, []string{"ebpf", "rust"}) {
+		t.Errorf("two tags = %v", got)
+	}
+	if got := len(store.PostsByTag["ebpf"]); got != 2 {
+		t.Errorf("expected 2 posts tagged ebpf, got %d", got)
+	}
+	if _, ok := store.PostsByTag["bpf"]; ok {
+		t.Error("expected aliases not to get their own tag")
+	}
+	if got := store.TagInfo("rust"); got.Name != "rust" {
+		t.Errorf("expected a tag missing from the taxonomy to be named by its slug, got %+v", got)
+	}
+}
+
+func TestReload_BadTagsKeepsPrevious(t *testing.T) {
+	dir := t.TempDir()
+	writeContent(t, dir, map[string]string{
+		"tags.yaml":   testTagsYAML,
+		"blog/one.md": "---\ntitle: One\ndate: 2024-01-01\ntags: [bpf]\n---\n",
+	})
+	prev, err := LoadFromDir(dir)
+	if err != nil {
+		t.Fatalf("LoadFromDir: %v", err)
+	}
+
+	writeContent(t, dir, map[string]string{"tags.yaml": "ebpf: [unclosed\n"})
+	store, err := Reload(dir, prev)
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if len(store.LoadErrors) != 1 || store.LoadErrors[0].File != tagsFile {
+		t.Errorf("expected a load error for %s, got %v", tagsFile, store.LoadErrors)
+	}
+	if got := store.PostsBySlug["one"].Tags; !slices.Equal(got, []string{"ebpf"}) {
+		t.Errorf("expected the previous taxonomy to be kept, got tags %v", got)
+	}
+}
@@ -0,0 +1,79 @@
+package content
+
+import (
+	"github.com/yuin/goldmark/ast"
+)
+
+// tocMaxLevel is the deepest heading level listed in a table of contents.
+const tocMaxLevel = 4
+
+// tocMinHeadings is how many headings a document needs before it gets a
+// table of contents, unless its frontmatter asks for one with "toc: true".
+const tocMinHeadings = 3
+
+type tocHeading struct {
+	level int
+	entry TOCEntry
+}
+
+// buildTOC returns the nested table of contents of a parsed document.
+func buildTOC(doc ast.Node, src []byte) []TOCEntry {
+	var headings []tocHeading
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) { //nolint:errcheck // the walker never fails
+		h, ok := n.(*ast.Heading)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+		id, _ := h.AttributeString("id")
+		idBytes, _ := id.([]byte)
+		if h.Level <= tocMaxLevel && len(idBytes) > 0 {
+			headings = append(headings, tocHeading{
+				level: h.Level,
+				entry: TOCEntry{ID: string(idBytes), Title: nodeText(h, src)},
+			})
+		}
+		return ast.WalkSkipChildren, nil
+	})
+
+	return nestTOC(headings)
+}
+
+// showTOC returns the table of contents a document should show, given its
+// "toc" frontmatter setting, which is nil if unset.
+func showTOC(toc []TOCEntry, show *bool) []TOCEntry {
+	if show != nil {
+		if *show {
+			return toc
+		}
+		return nil
+	}
+	if countTOC(toc) < tocMinHeadings {
+		return nil
+	}
+	return toc
+}
+
+func countTOC(toc []TOCEntry) int {
+	n := len(toc)
+	for _, e := range toc {
+		n += countTOC(e.Children)
+	}
+	return n
+}
+
+// nestTOC nests each heading under the nearest preceding heading of a
+// higher level. Skipped levels don't add empty entries.
+func nestTOC(headings []tocHeading) []TOCEntry {
+	var entries []TOCEntry
+	for i := 0; i < len(headings); {
+		j := i + 1
+		for j < len(headings) && headings[j].level > headings[i].level {
+			j++
+		}
+		entry := headings[i].entry
+		entry.Children = nestTOC(headings[i+1 : j])
+		entries = append(entries, entry)
+		i = j
+	}
+	return entries
+}
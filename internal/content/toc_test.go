@@ -0,0 +1,90 @@
+package content
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildTOC(t *testing.T) {
+	src := `---
+title: Post
+---
+
+# Intro
+
+## Setup
+
+#### Deep, skipping a level
+
+## The ` + "`main`" + ` function
+
+##### Too deep to list
+
+# Wrapping up
+`
+	var post BlogPost
+	_, toc, err := renderMarkdown(markdownSource{data: []byte(src)}, &post)
+	if err != nil {
+		t.Fatalf("renderMarkdown: %v", err)
+	}
+
+	want := []TOCEntry{
+		{ID: "intro", Title: "Intro", Children: []TOCEntry{
+			{ID: "setup", Title: "Setup", Children: []TOCEntry{
+				{ID: "deep-skipping-a-level", Title: "Deep, skipping a level"},
+			}},
+			{ID: "the-main-function", Title: "The main function"},
+		}},
+		{ID: "wrapping-up", Title: "Wrapping up"},
+	}
+	if !reflect.DeepEqual(toc, want) {
+		t.Errorf("unexpected TOC:\n got %+v\nwant %+v", toc, want)
+	}
+}
+
+func TestShowTOC(t *testing.T) {
+	yes, no := true, false
+	short := []TOCEntry{{ID: "a"}, {ID: "b"}}
+	long := []TOCEntry{{ID: "a", Children: []TOCEntry{{ID: "b"}}}, {ID: "c"}}
+
+	tests := []struct {
+		name string
+		toc  []TOCEntry
+		show *bool
+		want int
+	}{
+		{"short", short, nil, 0},
+		{"long", long, nil, 2},
+		{"short forced on", short, &yes, 2},
+		{"long forced off", long, &no, 0},
+	}
+	for _, tt := range tests {
+		if got := showTOC(tt.toc, tt.show); len(got) != tt.want {
+			t.Errorf("%s: expected %d entries, got %d", tt.name, tt.want, len(got))
+		}
+	}
+}
+
+func TestLoadFromDir_TOCFrontmatter(t *testing.T) {
+	dir := t.TempDir()
+	body := "\n## One\n\n## Two\n\n## Three\n"
+	writeContent(t, dir, map[string]string{
+		"blog/auto.md":     "---\ntitle: Auto\ndate: 2024-01-01\n---\n" + body,
+		"blog/off.md":      "---\ntitle: Off\ndate: 2024-01-01\ntoc: false\n---\n" + body,
+		"projects/tool.md": "---\ntitle: Tool\ndate: 2024-01-01\n---\n" + body,
+	})
+
+	store, err := LoadFromDir(dir)
+	if err != nil {
+		t.Fatalf("LoadFromDir: %v", err)
+	}
+	if got := len(store.PostsBySlug["auto"].TOC); got != 3 {
+		t.Errorf("expected 3 TOC entries, got %d", got)
+	}
+	if toc := store.PostsBySlug["off"].TOC; toc != nil {
+		t.Errorf("expected toc: false to hide the TOC, got %+v", toc)
+	}
+	if got := len(store.ProjectsBySlug["tool"].TOC); got != 3 {
+		t.Errorf("expected a project TOC, got %d entries", got)
+	}
+}
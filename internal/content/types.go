@@ -1,6 +1,7 @@
 package content
 
 import (
+	"crypto/sha256"
 	"fmt"
 	"html/template"
 	"sort"
@@ -15,9 +16,19 @@ type BlogPost struct {
 	Date        time.Time     `yaml:"date"`
 	Description string        `yaml:"description"`
 	Tags        []string      `yaml:"tags"`
+	Draft       bool          `yaml:"draft"`
+	Series      *PostSeries   `yaml:"series"`
 	Content     template.HTML // rendered markdown
 	PlainText   string        // raw markdown body (frontmatter stripped), for search
 	ReadingTime int           // estimated minutes to read
+	TOC         []TOCEntry    `yaml:"-"`   // table of contents; empty for short posts or "toc: false"
+	ShowTOC     *bool         `yaml:"toc"` // forces the TOC on or off
+
+	// Assets holds the other files of a page bundle by path relative to
+	// the bundle, served under the post's URL. It is nil for a flat file.
+	Assets map[string]Asset `yaml:"-"`
+
+	source []byte // a bundle's markdown, for RenderWithBase
 }
 
 type Project struct {
@@ -30,7 +41,14 @@ type Project struct {
 	URL         string        `yaml:"url"`
 	Status      string        `yaml:"status"`
 	Featured    bool          `yaml:"featured"`
+	Draft       bool          `yaml:"draft"`
 	Content     template.HTML // rendered markdown
+	PlainText   string        // raw markdown body, as for BlogPost
+	TOC         []TOCEntry    `yaml:"-"` // table of contents, as for BlogPost
+	ShowTOC     *bool         `yaml:"toc"`
+
+	// Assets holds the other files of a page bundle, as for BlogPost.
+	Assets map[string]Asset `yaml:"-"`
 }
 
 type Resume struct {
@@ -55,6 +73,7 @@ type ResumeDate struct {
 }
 
 type ResumeEntry struct {
+	ID           string         `yaml:"-"` // HTML id on the résumé page
 	Title        string         `yaml:"title"`
 	Organization string         `yaml:"organization"`
 	Location     string         `yaml:"location"`
@@ -78,6 +97,7 @@ type ResumeSkill struct {
 }
 
 type ResumePresentation struct {
+	ID            string        `yaml:"-"` // HTML id on the résumé page
 	Title         string        `yaml:"title"`
 	RawVenue      string        `yaml:"venue"`
 	Venue         template.HTML `yaml:"-"`
@@ -89,6 +109,7 @@ type ResumePubSection struct {
 	Section  string          `yaml:"section"`
 	RawItems []string        `yaml:"items"`
 	Items    []template.HTML `yaml:"-"`
+	ItemIDs  []string        `yaml:"-"` // HTML id of each item on the résumé page
 }
 
 type ResumeOSSSection struct {
@@ -97,6 +118,7 @@ type ResumeOSSSection struct {
 }
 
 type ResumeOSSProject struct {
+	ID         string          `yaml:"-"` // HTML id on the résumé page
 	Name       string          `yaml:"name"`
 	Tagline    string          `yaml:"tagline"`
 	RawBullets []string        `yaml:"bullets,omitempty"`
@@ -143,6 +165,15 @@ func (d ResumeDate) FormatDate() string {
 	return fmt.Sprint(d.Year)
 }
 
+// Time returns the start of the month, or year, d is. It returns the zero
+// time if d is unset.
+func (d ResumeDate) Time() time.Time {
+	if d.Year == 0 {
+		return time.Time{}
+	}
+	return time.Date(d.Year, time.Month(max(d.Month, 1)), 1, 0, 0, 0, 0, time.UTC)
+}
+
 // FormatDateRange renders "Start – End" or "Start – Present".
 func FormatDateRange(start ResumeDate, end *ResumeDate) string {
 	s := start.FormatDate()
@@ -152,10 +183,20 @@ func FormatDateRange(start ResumeDate, end *ResumeDate) string {
 	return s + " – " + end.FormatDate()
 }
 
+// TOCEntry is a heading in a table of contents, with the headings nested
+// under it.
+type TOCEntry struct {
+	ID       string // the heading's anchor
+	Title    string
+	Children []TOCEntry
+}
+
 type Redirect struct {
 	From string `yaml:"from"`
 	To   string `yaml:"to"`
 	Code int    `yaml:"code"`
+
+	line int // in _redirects.yaml, for Check
 }
 
 type ContentStore struct {
@@ -163,12 +204,70 @@ type ContentStore struct {
 	PostsBySlug map[string]*BlogPost
 	PostsByTag  map[string][]*BlogPost
 
+	// Search indexes the published posts for full-text search.
+	Search *SearchIndex
+
+	// SiteSearch indexes the published posts and projects and the résumé
+	// for searching the whole site.
+	SiteSearch *SiteIndex
+
+	// PostsBySeries maps a series slug to its published parts, in reading
+	// order.
+	PostsBySeries map[string][]*BlogPost
+
+	// Tags holds the taxonomy from tags.yaml by tag slug. Post tags are
+	// normalized to these slugs when loaded.
+	Tags map[string]TagInfo
+
+	// UnpublishedBySlug holds drafts and scheduled posts. They are kept out
+	// of every listing and are only reachable through preview links.
+	UnpublishedBySlug map[string]*BlogPost
+
 	Projects       []Project
 	ProjectsBySlug map[string]*Project
 
 	Resume *Resume
 
 	Redirects map[string]Redirect
+
+	// Commit and CommitTime identify the content repo commit the store was
+	// loaded from. They are empty when serving a local directory.
+	Commit     string
+	CommitTime time.Time
+
+	// NextPublish is the date of the earliest scheduled (future-dated,
+	// non-draft) post or project that was held back, or zero if none.
+	NextPublish time.Time
+
+	// LoadErrors lists files that Reload skipped because they failed to
+	// load. The previous version of each is served in its place, if any.
+	LoadErrors []LoadError
+
+	// rendered remembers each markdown file's hash and decoded item, so a
+	// reload only re-renders files that changed.
+	rendered map[string]renderedFile
+
+	// assetFiles holds every bundle asset by path on disk, so a reload
+	// only hashes files that changed.
+	assetFiles map[string]Asset
+
+	// tagAliases maps every folded spelling of a tag in Tags to its slug.
+	tagAliases map[string]string
+}
+
+type renderedFile struct {
+	hash [sha256.Size]byte
+	item any
+}
+
+// LoadError records a content file that failed to load.
+type LoadError struct {
+	File string // relative to the content dir
+	Err  error
+}
+
+func (e LoadError) Error() string {
+	return e.File + ": " + e.Err.Error()
 }
 
 // RelatedPosts returns up to `limit` posts related to the given slug,
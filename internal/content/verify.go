@@ -0,0 +1,204 @@
+package content
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"golang.org/x/crypto/ssh"
+)
+
+// ErrUntrustedCommit is returned by a sync when the commit it would load
+// isn't signed by any of the configured TrustedKeys.
+var ErrUntrustedCommit = errors.New("commit is not signed by a trusted key")
+
+const (
+	pgpKeyBegin = "-----BEGIN PGP PUBLIC KEY BLOCK-----"
+	pgpKeyEnd   = "-----END PGP PUBLIC KEY BLOCK-----"
+	sshSigBegin = "-----BEGIN SSH SIGNATURE-----"
+
+	// sshSigNamespace is the namespace git uses for SSH commit signatures.
+	sshSigNamespace = "git"
+)
+
+// TrustedKeys is the set of keys content commits must be signed with.
+type TrustedKeys struct {
+	pgp []string // armored key blocks, as commit.Verify wants them
+	ssh []ssh.PublicKey
+}
+
+// ParseTrustedKeys reads armored OpenPGP public key blocks and SSH public
+// keys, one per line in authorized_keys or allowed_signers format, in any
+// mix. Blank lines and lines starting with # are ignored.
+func ParseTrustedKeys(data []byte) (*TrustedKeys, error) {
+	keys := &TrustedKeys{}
+	rest := string(data)
+	for {
+		before, block, found := strings.Cut(rest, pgpKeyBegin)
+		if err := keys.parseSSH(before); err != nil {
+			return nil, err
+		}
+		if !found {
+			break
+		}
+		body, after, ok := strings.Cut(block, pgpKeyEnd)
+		if !ok {
+			return nil, fmt.Errorf("unterminated PGP public key block")
+		}
+		armored := pgpKeyBegin + body + pgpKeyEnd + "\n"
+		if _, err := openpgp.ReadArmoredKeyRing(strings.NewReader(armored)); err != nil {
+			return nil, fmt.Errorf("parsing PGP public key: %w", err)
+		}
+		keys.pgp = append(keys.pgp, armored)
+		rest = after
+	}
+
+	if len(keys.pgp) == 0 && len(keys.ssh) == 0 {
+		return nil, fmt.Errorf("no trusted keys found")
+	}
+	return keys, nil
+}
+
+func (k *TrustedKeys) parseSSH(text string) error {
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+		if err != nil {
+			return fmt.Errorf("parsing SSH public key on line %d: %w", i+1, err)
+		}
+		k.ssh = append(k.ssh, pub)
+	}
+	return nil
+}
+
+// Verify checks that commit carries a valid signature by one of k.
+func (k *TrustedKeys) Verify(commit *object.Commit) error {
+	sig := commit.PGPSignature
+	switch {
+	case sig == "":
+		return fmt.Errorf("%w: %s is unsigned", ErrUntrustedCommit, commit.Hash)
+	case strings.HasPrefix(sig, sshSigBegin):
+		msg, err := signedPayload(commit)
+		if err != nil {
+			return err
+		}
+		if err := k.verifySSH(msg, []byte(sig)); err != nil {
+			return fmt.Errorf("%w: %s: %w", ErrUntrustedCommit, commit.Hash, err)
+		}
+		return nil
+	default:
+		for _, ring := range k.pgp {
+			if _, err := commit.Verify(ring); err == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: %s", ErrUntrustedCommit, commit.Hash)
+	}
+}
+
+// signedPayload is the commit as it was encoded when it was signed.
+func signedPayload(commit *object.Commit) ([]byte, error) {
+	obj := &plumbing.MemoryObject{}
+	if err := commit.EncodeWithoutSignature(obj); err != nil {
+		return nil, fmt.Errorf("encoding commit: %w", err)
+	}
+	r, err := obj.Reader()
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// sshSignature is the wire format of an armored SSH signature, as
+// described in OpenSSH's PROTOCOL.sshsig.
+type sshSignature struct {
+	Magic         [6]byte
+	Version       uint32
+	PublicKey     []byte
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Signature     []byte
+}
+
+// sshSignedData is what an SSH signature actually signs.
+type sshSignedData struct {
+	Magic         [6]byte
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Hash          []byte
+}
+
+var sshSigMagic = [6]byte{'S', 'S', 'H', 'S', 'I', 'G'}
+
+func (k *TrustedKeys) verifySSH(msg, armored []byte) error {
+	block, _ := pem.Decode(armored)
+	if block == nil || block.Type != "SSH SIGNATURE" {
+		return fmt.Errorf("malformed SSH signature")
+	}
+	var sig sshSignature
+	if err := ssh.Unmarshal(block.Bytes, &sig); err != nil {
+		return fmt.Errorf("parsing SSH signature: %w", err)
+	}
+	if sig.Magic != sshSigMagic || sig.Version != 1 {
+		return fmt.Errorf("unsupported SSH signature")
+	}
+	if sig.Namespace != sshSigNamespace {
+		return fmt.Errorf("SSH signature has namespace %q, want %q", sig.Namespace, sshSigNamespace)
+	}
+
+	pub, err := ssh.ParsePublicKey(sig.PublicKey)
+	if err != nil {
+		return fmt.Errorf("parsing SSH signature key: %w", err)
+	}
+	if !k.trustsSSH(pub) {
+		return fmt.Errorf("signed by untrusted key %s", ssh.FingerprintSHA256(pub))
+	}
+
+	var hash []byte
+	switch sig.HashAlgorithm {
+	case "sha256":
+		h := sha256.Sum256(msg)
+		hash = h[:]
+	case "sha512":
+		h := sha512.Sum512(msg)
+		hash = h[:]
+	default:
+		return fmt.Errorf("unsupported SSH signature hash %q", sig.HashAlgorithm)
+	}
+
+	var s ssh.Signature
+	if err := ssh.Unmarshal(sig.Signature, &s); err != nil {
+		return fmt.Errorf("parsing SSH signature blob: %w", err)
+	}
+	signed := ssh.Marshal(sshSignedData{
+		Magic:         sshSigMagic,
+		Namespace:     sig.Namespace,
+		Reserved:      sig.Reserved,
+		HashAlgorithm: sig.HashAlgorithm,
+		Hash:          hash,
+	})
+	return pub.Verify(signed, &s)
+}
+
+func (k *TrustedKeys) trustsSSH(pub ssh.PublicKey) bool {
+	b := pub.Marshal()
+	for _, t := range k.ssh {
+		if bytes.Equal(t.Marshal(), b) {
+			return true
+		}
+	}
+	return false
+}
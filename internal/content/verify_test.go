@@ -0,0 +1,165 @@
+package content
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/pem"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/go-git/go-git/v5"
+	"golang.org/x/crypto/ssh"
+)
+
+// sshCommitSigner signs commits the way git does with gpg.format=ssh.
+type sshCommitSigner struct {
+	signer ssh.Signer
+}
+
+func (s sshCommitSigner) Sign(message io.Reader) ([]byte, error) {
+	msg, err := io.ReadAll(message)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha512.Sum512(msg)
+	sig, err := s.signer.Sign(rand.Reader, ssh.Marshal(sshSignedData{
+		Magic:         sshSigMagic,
+		Namespace:     sshSigNamespace,
+		HashAlgorithm: "sha512",
+		Hash:          hash[:],
+	}))
+	if err != nil {
+		return nil, err
+	}
+	blob := ssh.Marshal(sshSignature{
+		Magic:         sshSigMagic,
+		Version:       1,
+		PublicKey:     s.signer.PublicKey().Marshal(),
+		Namespace:     sshSigNamespace,
+		HashAlgorithm: "sha512",
+		Signature:     ssh.Marshal(sig),
+	})
+	return pem.EncodeToMemory(&pem.Block{Type: "SSH SIGNATURE", Bytes: blob}), nil
+}
+
+func newPGPKey(t *testing.T) (*openpgp.Entity, string) {
+	t.Helper()
+	entity, err := openpgp.NewEntity("Test", "", "test@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := entity.Serialize(w); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return entity, buf.String() + "\n"
+}
+
+func TestParseTrustedKeys(t *testing.T) {
+	sshKey, _ := newSSHKey(t)
+	_, pgpKey := newPGPKey(t)
+	authorized := string(ssh.MarshalAuthorizedKey(sshKey.PublicKey()))
+
+	keys, err := ParseTrustedKeys([]byte("# deploy keys\n" + authorized + "\n" + pgpKey + "\nme@example.com " + authorized))
+	if err != nil {
+		t.Fatalf("ParseTrustedKeys: %v", err)
+	}
+	if len(keys.ssh) != 2 || len(keys.pgp) != 1 {
+		t.Errorf("expected 2 SSH and 1 PGP key, got %d and %d", len(keys.ssh), len(keys.pgp))
+	}
+
+	for name, data := range map[string]string{
+		"empty":        "# nothing here\n",
+		"bad SSH key":  "ssh-ed25519 not-base64\n",
+		"unterminated": pgpKey[:len(pgpKey)/2],
+	} {
+		if _, err := ParseTrustedKeys([]byte(data)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestSyncer_SignedCommits(t *testing.T) {
+	remoteDir, remote := newRemote(t)
+
+	trustedSSH, _ := newSSHKey(t)
+	untrustedSSH, _ := newSSHKey(t)
+	trustedPGP, pgpKey := newPGPKey(t)
+	keys, err := ParseTrustedKeys([]byte(string(ssh.MarshalAuthorizedKey(trustedSSH.PublicKey())) + pgpKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := SyncConfig{
+		RepoURL:     remoteDir,
+		Branch:      "main",
+		Dir:         filepath.Join(t.TempDir(), "content"),
+		TrustedKeys: keys,
+	}
+	store := NewAtomicStore()
+	s := NewSyncer(cfg, store)
+
+	// The remote's first commit is unsigned, so nothing can be cloned.
+	if err := s.Sync(); !errors.Is(err, ErrUntrustedCommit) {
+		t.Fatalf("expected ErrUntrustedCommit, got %v", err)
+	}
+	if _, err := os.Stat(cfg.Dir); !os.IsNotExist(err) {
+		t.Errorf("expected no clone of an untrusted commit, got %v", err)
+	}
+
+	commitPostWith(t, remote, "ssh.md", "SSH", &git.CommitOptions{Signer: sshCommitSigner{trustedSSH}})
+	if err := s.Sync(); err != nil {
+		t.Fatalf("Sync of SSH-signed commit: %v", err)
+	}
+	if got := postTitles(t, store); len(got) != 2 {
+		t.Fatalf("expected 2 posts, got %v", got)
+	}
+
+	commitPostWith(t, remote, "pgp.md", "PGP", &git.CommitOptions{SignKey: trustedPGP})
+	if err := s.Sync(); err != nil {
+		t.Fatalf("Sync of PGP-signed commit: %v", err)
+	}
+	good := store.Load()
+
+	rejected := []struct {
+		name string
+		opts *git.CommitOptions
+	}{
+		{"unsigned", &git.CommitOptions{}},
+		{"untrusted SSH key", &git.CommitOptions{Signer: sshCommitSigner{untrustedSSH}}},
+	}
+	for _, tt := range rejected {
+		t.Run(tt.name, func(t *testing.T) {
+			name := strings.ReplaceAll(tt.name, " ", "-") + ".md"
+			commitPostWith(t, remote, name, tt.name, tt.opts)
+			if err := s.Sync(); !errors.Is(err, ErrUntrustedCommit) {
+				t.Fatalf("expected ErrUntrustedCommit, got %v", err)
+			}
+			if store.Load() != good {
+				t.Error("expected the previous content to stay loaded")
+			}
+			if _, err := os.Stat(filepath.Join(cfg.Dir, "blog", name)); !os.IsNotExist(err) {
+				t.Errorf("expected the untrusted commit not to be checked out, got %v", err)
+			}
+			if st := s.Status(); !strings.Contains(st.LastError, ErrUntrustedCommit.Error()) {
+				t.Errorf("expected the rejection in the sync status, got %q", st.LastError)
+			} else if st.LastErrorStage != StageVerify {
+				t.Errorf("expected stage %q, got %q", StageVerify, st.LastErrorStage)
+			}
+		})
+	}
+}
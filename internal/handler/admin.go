@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/willfindlay/williamfindlaycom/internal/content"
+)
+
+type snapshotsResponse struct {
+	Snapshots []*contentStatus `json:"snapshots"` // most recent first
+}
+
+// AdminSnapshots lists the content snapshots that can be rolled back to.
+func (d *Deps) AdminSnapshots() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := snapshotsResponse{Snapshots: []*contentStatus{}}
+		for _, cs := range d.Store.History() {
+			resp.Snapshots = append(resp.Snapshots, newContentStatus(cs))
+		}
+		writeAdminJSON(w, http.StatusOK, resp)
+	}
+}
+
+// AdminRollback puts an earlier content snapshot back in service. The
+// "commit" form value selects the snapshot; without it the previous one is
+// used. The rollback holds until a new commit is synced.
+func (d *Deps) AdminRollback() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		commit := r.FormValue("commit")
+		cs, err := d.Store.Rollback(commit)
+		if errors.Is(err, content.ErrNoSnapshot) {
+			http.Error(w, "No such snapshot", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.Error("content rollback failed", "err", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		slog.Warn("content rolled back", "commit", cs.Commit, "remote", r.RemoteAddr)
+		writeAdminJSON(w, http.StatusOK, newContentStatus(cs))
+	}
+}
+
+type diagnosticsData struct {
+	PageData
+	Sync  content.SyncStatus
+	Store *content.ContentStore
+}
+
+// AdminDiagnostics shows how the last sync went and which content files
+// were skipped because they failed to load.
+func (d *Deps) AdminDiagnostics(sync func() content.SyncStatus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data := diagnosticsData{
+			PageData: d.basePage(""),
+			Sync:     sync(),
+			Store:    d.Store.Load(),
+		}
+		data.PageTitle = "Diagnostics"
+		data.NoIndex = true
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("X-Robots-Tag", "noindex, nofollow")
+		d.render(w, "templates/admin/diagnostics.html", data)
+	}
+}
+
+func writeAdminJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("admin encode error", "err", err)
+	}
+}
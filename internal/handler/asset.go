@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/willfindlay/williamfindlaycom/internal/content"
+)
+
+// BlogAsset serves a file from a published post's page bundle.
+func (d *Deps) BlogAsset() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		store := d.Store.Load()
+		if store == nil {
+			d.notFound(w, r)
+			return
+		}
+		post, ok := store.PostsBySlug[r.PathValue("slug")]
+		if !ok {
+			d.notFound(w, r)
+			return
+		}
+		d.serveAsset(w, r, post.Assets, r.PathValue("file"), store.CommitTime)
+	}
+}
+
+// ProjectAsset serves a file from a project's page bundle.
+func (d *Deps) ProjectAsset() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		store := d.Store.Load()
+		if store == nil {
+			d.notFound(w, r)
+			return
+		}
+		proj, ok := store.ProjectsBySlug[r.PathValue("slug")]
+		if !ok {
+			d.notFound(w, r)
+			return
+		}
+		d.serveAsset(w, r, proj.Assets, r.PathValue("file"), store.CommitTime)
+	}
+}
+
+// serveAsset serves the named file, or a resized variant of an image, from
+// a page bundle's assets, or the 404 page if there is no such file. modTime
+// may be zero.
+func (d *Deps) serveAsset(w http.ResponseWriter, r *http.Request, assets map[string]content.Asset, name string, modTime time.Time) {
+	if a, ok := assets[name]; ok {
+		f, err := a.Open()
+		if err != nil {
+			slog.Warn("bundle asset", "asset", name, "err", err)
+			d.notFound(w, r)
+			return
+		}
+		defer f.Close() //nolint:errcheck // read-only
+		http.ServeContent(w, r, path.Base(name), modTime, f)
+		return
+	}
+
+	data, ok, err := d.Images.Variant(assets, name)
+	if err != nil {
+		slog.Error("image variant", "asset", name, "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		d.notFound(w, r)
+		return
+	}
+	http.ServeContent(w, r, path.Base(name), modTime, bytes.NewReader(data))
+}
@@ -2,9 +2,12 @@ package handler
 
 import (
 	"encoding/json"
+	"fmt"
 	"html/template"
+	"maps"
 	"net/http"
-	"sort"
+	"net/url"
+	"slices"
 	"strings"
 
 	"github.com/willfindlay/williamfindlaycom/internal/config"
@@ -15,21 +18,47 @@ type blogListData struct {
 	PageData
 	Posts        []content.BlogPost
 	AllPostsJSON template.JS
-	AllTags      []string
+	AllTags      []content.TagInfo
 	ActiveTags   []string
 	ActiveTagSet map[string]bool
 	SearchQuery  string
+	SearchError  string                   // why SearchQuery couldn't be parsed
+	Snippets     map[string]template.HTML // by slug: the passage each result matched in
+
+	// SearchSuggestion is a corrected SearchQuery, whose results are shown
+	// when SearchQuery has none.
+	SearchSuggestion string
 }
 
 type blogPostData struct {
 	PageData
 	Post         *content.BlogPost
+	Tags         []content.TagInfo
 	PrevPost     *content.BlogPost // older
 	NextPost     *content.BlogPost // newer
 	RelatedPosts []*content.BlogPost
+	Series       *seriesData // nil unless the post is part of a series
 	Giscus       config.GiscusConfig
 }
 
+type seriesData struct {
+	Name  string
+	Slug  string
+	Part  int // 1-based position of the current post
+	Posts []*content.BlogPost
+}
+
+type blogSeriesData struct {
+	PageData
+	Series seriesData
+}
+
+type blogTagData struct {
+	PageData
+	Tag   content.TagInfo
+	Posts []*content.BlogPost
+}
+
 func (d *Deps) BlogList() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		store := d.Store.Load()
@@ -50,10 +79,32 @@ func (d *Deps) BlogList() http.HandlerFunc {
 			filtered := store.Posts
 
 			if query != "" {
-				filtered = content.SearchPosts(filtered, query)
+				var hits []content.SearchResult
+				hits, data.SearchError, data.SearchSuggestion = runSearch(store.Search, query)
+				filtered = make([]content.BlogPost, len(hits))
+				data.Snippets = make(map[string]template.HTML)
+				for i, h := range hits {
+					filtered[i] = *h.Post
+					if h.Snippet != "" {
+						data.Snippets[h.Post.Slug] = h.Snippet
+					}
+				}
 			}
 
 			if len(tags) > 0 {
+				// Send other spellings of a tag to its canonical one, which
+				// the client-side filter matches against.
+				canonical := make([]string, len(tags))
+				for i, t := range tags {
+					canonical[i] = store.CanonicalTag(t)
+				}
+				if !slices.Equal(canonical, tags) {
+					q := r.URL.Query()
+					q["tag"] = canonical
+					http.Redirect(w, r, "/blog?"+q.Encode(), http.StatusMovedPermanently)
+					return
+				}
+
 				for _, t := range tags {
 					data.ActiveTags = append(data.ActiveTags, t)
 					data.ActiveTagSet[t] = true
@@ -86,14 +137,9 @@ func (d *Deps) BlogList() http.HandlerFunc {
 				data.AllPostsJSON = template.JS(b)
 			}
 
-			allTagSet := make(map[string]bool)
-			for t := range store.PostsByTag {
-				allTagSet[t] = true
-			}
-			for t := range allTagSet {
-				data.AllTags = append(data.AllTags, t)
+			for _, t := range slices.Sorted(maps.Keys(store.PostsByTag)) {
+				data.AllTags = append(data.AllTags, store.TagInfo(t))
 			}
-			sort.Strings(data.AllTags)
 		}
 
 		d.render(w, "templates/blog/list.html", data)
@@ -116,26 +162,111 @@ func (d *Deps) BlogPost() http.HandlerFunc {
 			return
 		}
 
-		data := blogPostData{PageData: d.basePage("blog"), Post: post, Giscus: d.Giscus}
-		for i, p := range store.Posts {
-			if p.Slug == slug {
-				if i+1 < len(store.Posts) {
-					data.PrevPost = &store.Posts[i+1] // older
-				}
-				if i > 0 {
-					data.NextPost = &store.Posts[i-1] // newer
-				}
-				break
+		d.renderBlogPost(w, store, post, blogPostData{PageData: d.basePage("blog"), Giscus: d.Giscus})
+	}
+}
+
+// renderBlogPost fills in the post-specific fields of data and renders the
+// post page. Navigation and related posts are only found for published
+// posts, since unpublished ones aren't part of store.Posts.
+func (d *Deps) renderBlogPost(w http.ResponseWriter, store *content.ContentStore, post *content.BlogPost, data blogPostData) {
+	data.Post = post
+	for i, p := range store.Posts {
+		if p.Slug == post.Slug {
+			if i+1 < len(store.Posts) {
+				data.PrevPost = &store.Posts[i+1] // older
 			}
+			if i > 0 {
+				data.NextPost = &store.Posts[i-1] // newer
+			}
+			break
 		}
-		data.PageTitle = post.Title
-		data.Description = post.Description
-		data.CanonicalURL = d.SiteURL + "/blog/" + slug
-		data.OGType = "article"
+	}
+	data.PageTitle = post.Title
+	data.Description = post.Description
+	data.OGType = "article"
+	// An unpublished post's URL is a 404 until it goes live, so previews
+	// don't point search engines or social cards at it.
+	if _, published := store.PostsBySlug[post.Slug]; published {
+		data.CanonicalURL = d.SiteURL + "/blog/" + post.Slug
 		data.JSONLD = buildBlogPostingJSONLD(post, d.SiteURL)
-		data.RelatedPosts = store.RelatedPosts(slug, 3)
+		d.setOGImage(&data.PageData, "blog", post.Slug)
+	}
+	for _, t := range post.Tags {
+		data.Tags = append(data.Tags, store.TagInfo(t))
+	}
+	data.RelatedPosts = store.RelatedPosts(post.Slug, 3)
+	if post.Series != nil {
+		parts := store.PostsBySeries[post.Series.Slug()]
+		for i, p := range parts {
+			if p.Slug == post.Slug {
+				data.Series = &seriesData{Name: parts[0].Series.Name, Slug: post.Series.Slug(), Part: i + 1, Posts: parts}
+				break
+			}
+		}
+	}
+
+	d.render(w, "templates/blog/post.html", data)
+}
+
+// BlogSeries lists the parts of a series in reading order.
+func (d *Deps) BlogSeries() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slug := r.PathValue("name")
+		store := d.Store.Load()
+
+		if store == nil {
+			d.notFound(w, r)
+			return
+		}
+		parts, ok := store.PostsBySeries[slug]
+		if !ok {
+			d.notFound(w, r)
+			return
+		}
+
+		data := blogSeriesData{PageData: d.basePage("blog")}
+		data.Series = seriesData{Name: parts[0].Series.Name, Slug: slug, Posts: parts}
+		data.PageTitle = data.Series.Name
+		data.Description = fmt.Sprintf("A %d-part series by William Findlay", len(parts))
+		data.CanonicalURL = d.SiteURL + "/blog/series/" + slug
+		data.JSONLD = buildCollectionPageJSONLD(data.Series.Name, data.Description, data.CanonicalURL)
+
+		d.render(w, "templates/blog/series.html", data)
+	}
+}
+
+// BlogTag lists the posts with a tag. Other spellings of the tag redirect
+// to its canonical page.
+func (d *Deps) BlogTag() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tag := r.PathValue("tag")
+		store := d.Store.Load()
+
+		if store == nil {
+			d.notFound(w, r)
+			return
+		}
+		if canonical := store.CanonicalTag(tag); canonical != tag {
+			http.Redirect(w, r, "/blog/tags/"+url.PathEscape(canonical), http.StatusMovedPermanently)
+			return
+		}
+		posts, ok := store.PostsByTag[tag]
+		if !ok {
+			d.notFound(w, r)
+			return
+		}
+
+		data := blogTagData{PageData: d.basePage("blog"), Tag: store.TagInfo(tag), Posts: posts}
+		data.PageTitle = data.Tag.Name
+		data.Description = data.Tag.Description
+		if data.Description == "" {
+			data.Description = "Posts about " + data.Tag.Name + " by William Findlay"
+		}
+		data.CanonicalURL = d.SiteURL + "/blog/tags/" + url.PathEscape(tag)
+		data.JSONLD = buildCollectionPageJSONLD(data.Tag.Name, data.Description, data.CanonicalURL)
 
-		d.render(w, "templates/blog/post.html", data)
+		d.render(w, "templates/blog/tag.html", data)
 	}
 }
 
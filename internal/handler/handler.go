@@ -8,16 +8,21 @@ import (
 
 	"github.com/willfindlay/williamfindlaycom/internal/config"
 	"github.com/willfindlay/williamfindlaycom/internal/content"
+	"github.com/willfindlay/williamfindlaycom/internal/ogimage"
 	"github.com/willfindlay/williamfindlaycom/internal/render"
 )
 
 type Deps struct {
-	Store     *content.AtomicStore
-	Renderer  *render.Renderer
-	SiteTitle string
-	SiteURL   string
-	Particles config.ParticleConfig
-	Giscus    config.GiscusConfig
+	Store         *content.AtomicStore
+	Renderer      *render.Renderer
+	Images        *content.ImageCache // nil to resize images without caching
+	Cards         *ogimage.Renderer
+	SiteTitle     string
+	SiteURL       string
+	PreviewSecret string
+	LiveReload    bool
+	Particles     config.ParticleConfig
+	Giscus        config.GiscusConfig
 }
 
 type PageData struct {
@@ -28,21 +33,26 @@ type PageData struct {
 	CanonicalURL string
 	OGType       string
 	OGImage      string
+	TwitterCard  string // "summary", or "summary_large_image" for a generated card
 	Author       string
 	JSONLD       template.JS
 	ActiveNav    string
+	NoIndex      bool
+	LiveReload   bool
 	Particles    config.ParticleConfig
 }
 
 func (d *Deps) basePage(activeNav string) PageData {
 	return PageData{
-		SiteTitle: d.SiteTitle,
-		SiteURL:   d.SiteURL,
-		OGType:    "website",
-		OGImage:   d.SiteURL + "/static/og-image.png",
-		Author:    "William Findlay",
-		ActiveNav: activeNav,
-		Particles: d.Particles,
+		SiteTitle:   d.SiteTitle,
+		SiteURL:     d.SiteURL,
+		OGType:      "website",
+		OGImage:     d.SiteURL + "/static/og-image.png",
+		TwitterCard: "summary",
+		Author:      "William Findlay",
+		ActiveNav:   activeNav,
+		LiveReload:  d.LiveReload,
+		Particles:   d.Particles,
 	}
 }
 
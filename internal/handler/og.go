@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/willfindlay/williamfindlaycom/internal/content"
+	"github.com/willfindlay/williamfindlaycom/internal/ogimage"
+)
+
+// ogImagePath returns the path of the social card for a post or project.
+func ogImagePath(section, slug string) string {
+	return "/og/" + section + "/" + slug + ".png"
+}
+
+// setOGImage points a page's social card at the generated image.
+func (d *Deps) setOGImage(data *PageData, section, slug string) {
+	data.OGImage = d.SiteURL + ogImagePath(section, slug)
+	data.TwitterCard = "summary_large_image"
+}
+
+// BlogOGImage serves the social card of a published post.
+func (d *Deps) BlogOGImage() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		store := d.Store.Load()
+		slug, ok := strings.CutSuffix(r.PathValue("file"), ".png")
+		if store == nil || !ok {
+			d.notFound(w, r)
+			return
+		}
+		post, ok := store.PostsBySlug[slug]
+		if !ok {
+			d.notFound(w, r)
+			return
+		}
+
+		meta := post.Date.Format("January 2, 2006")
+		if post.ReadingTime > 0 {
+			meta += fmt.Sprintf(" · %d min read", post.ReadingTime)
+		}
+		d.serveOGImage(w, r, store, ogimage.Card{
+			Kicker: "Blog",
+			Title:  post.Title,
+			Meta:   meta,
+			Tags:   post.Tags,
+		})
+	}
+}
+
+// ProjectOGImage serves the social card of a project.
+func (d *Deps) ProjectOGImage() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		store := d.Store.Load()
+		slug, ok := strings.CutSuffix(r.PathValue("file"), ".png")
+		if store == nil || !ok {
+			d.notFound(w, r)
+			return
+		}
+		proj, ok := store.ProjectsBySlug[slug]
+		if !ok {
+			d.notFound(w, r)
+			return
+		}
+
+		d.serveOGImage(w, r, store, ogimage.Card{
+			Kicker: "Project",
+			Title:  proj.Title,
+			Meta:   proj.Description,
+			Tags:   proj.Tags,
+		})
+	}
+}
+
+// serveOGImage renders card with the site's branding, or serves it from
+// the image cache if it was rendered before.
+func (d *Deps) serveOGImage(w http.ResponseWriter, r *http.Request, store *content.ContentStore, card ogimage.Card) {
+	card.Site = d.SiteTitle
+	if u, err := url.Parse(d.SiteURL); err == nil {
+		card.Domain = u.Host
+	}
+
+	key, err := json.Marshal(card)
+	if err != nil {
+		slog.Error("og image key", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	sum := sha256.Sum256(append(key, ogimage.Version...))
+	data, err := d.Images.Cached("og-"+hex.EncodeToString(sum[:])+".png", func() ([]byte, error) {
+		return d.Cards.Render(card)
+	})
+	if err != nil {
+		slog.Error("og image", "title", card.Title, "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	http.ServeContent(w, r, "og.png", store.CommitTime, bytes.NewReader(data))
+}
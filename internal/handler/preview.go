@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+)
+
+// PreviewToken returns the token that unlocks the preview of the post with
+// the given slug. It is an HMAC of the slug keyed by the preview secret, so
+// a token can't be guessed or reused for a different post.
+func PreviewToken(secret, slug string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("blog/" + slug))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// PreviewURL returns the secret preview link for the post with the given slug.
+func PreviewURL(siteURL, secret, slug string) string {
+	return siteURL + "/preview/" + PreviewToken(secret, slug) + "/blog/" + slug
+}
+
+// BlogPreview renders a draft or scheduled post for anyone holding its
+// preview link. Previews are never indexed and have comments disabled.
+// Once the post is published the link redirects to its real URL.
+func (d *Deps) BlogPreview() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slug := r.PathValue("slug")
+		token := r.PathValue("token")
+		store := d.Store.Load()
+
+		if store == nil || !d.validPreviewToken(token, slug) {
+			d.notFound(w, r)
+			return
+		}
+
+		if _, ok := store.PostsBySlug[slug]; ok {
+			http.Redirect(w, r, "/blog/"+slug, http.StatusFound)
+			return
+		}
+
+		post, ok := store.UnpublishedBySlug[slug]
+		if !ok {
+			d.notFound(w, r)
+			return
+		}
+
+		// Bundle assets of unpublished posts are only served behind the
+		// preview token too, so point the post's links and srcsets there.
+		if len(post.Assets) > 0 {
+			p := *post
+			var err error
+			p.Content, err = post.RenderWithBase("/preview/" + token + "/blog/" + slug + "/")
+			if err != nil {
+				slog.Error("preview render error", "slug", slug, "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			post = &p
+		}
+
+		w.Header().Set("X-Robots-Tag", "noindex, nofollow")
+		data := blogPostData{PageData: d.basePage("blog")}
+		data.NoIndex = true
+		d.renderBlogPost(w, store, post, data)
+	}
+}
+
+// BlogPreviewAsset serves a file from an unpublished post's page bundle to
+// anyone holding the post's preview link.
+func (d *Deps) BlogPreviewAsset() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slug := r.PathValue("slug")
+		file := r.PathValue("file")
+		store := d.Store.Load()
+
+		if store == nil || !d.validPreviewToken(r.PathValue("token"), slug) {
+			d.notFound(w, r)
+			return
+		}
+
+		if _, ok := store.PostsBySlug[slug]; ok {
+			http.Redirect(w, r, "/blog/"+slug+"/"+file, http.StatusFound)
+			return
+		}
+
+		post, ok := store.UnpublishedBySlug[slug]
+		if !ok {
+			d.notFound(w, r)
+			return
+		}
+
+		w.Header().Set("X-Robots-Tag", "noindex, nofollow")
+		d.serveAsset(w, r, post.Assets, file, store.CommitTime)
+	}
+}
+
+func (d *Deps) validPreviewToken(token, slug string) bool {
+	return d.PreviewSecret != "" && hmac.Equal([]byte(token), []byte(PreviewToken(d.PreviewSecret, slug)))
+}
@@ -54,6 +54,7 @@ func (d *Deps) ProjectDetail() http.HandlerFunc {
 		data.PageTitle = proj.Title
 		data.Description = proj.Description
 		data.CanonicalURL = d.SiteURL + "/projects/" + slug
+		d.setOGImage(&data.PageData, "projects", slug)
 
 		d.render(w, "templates/projects/project.html", data)
 	}
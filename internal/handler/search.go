@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/willfindlay/williamfindlaycom/internal/content"
+)
+
+type searchData struct {
+	PageData
+	Query      string
+	Error      string // why Query couldn't be parsed
+	Suggestion string // a corrected Query, whose results are shown when Query has none
+	Groups     []searchGroup
+}
+
+// searchGroup is the results of one kind, in the order they ranked.
+type searchGroup struct {
+	Name    string
+	Results []content.SiteResult
+}
+
+// searchGroups lists the kinds of results in the order the page shows them.
+var searchGroups = []struct {
+	kind content.SiteKind
+	name string
+}{
+	{content.KindPost, "Blog posts"},
+	{content.KindProject, "Projects"},
+	{content.KindResume, "Résumé"},
+}
+
+// Search searches posts, projects and the résumé together.
+func (d *Deps) Search() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		store := d.Store.Load()
+
+		data := searchData{PageData: d.basePage("search")}
+		data.PageTitle = "Search"
+		data.Description = "Search the blog posts, projects and résumé of William Findlay"
+		data.CanonicalURL = d.SiteURL + "/search"
+		data.Query = strings.TrimSpace(r.URL.Query().Get("q"))
+
+		if store != nil && data.Query != "" {
+			// Keep result pages out of search engines, which index the
+			// pages they link to directly.
+			data.NoIndex = true
+
+			var hits []content.SiteResult
+			hits, data.Error, data.Suggestion = runSearch(store.SiteSearch, data.Query)
+			byKind := make(map[content.SiteKind][]content.SiteResult)
+			for _, h := range hits {
+				byKind[h.Kind] = append(byKind[h.Kind], h)
+			}
+			for _, g := range searchGroups {
+				if results := byKind[g.kind]; len(results) > 0 {
+					data.Groups = append(data.Groups, searchGroup{Name: g.name, Results: results})
+				}
+			}
+		}
+
+		d.render(w, "templates/search.html", data)
+	}
+}
+
+// searcher is an index runSearch can search.
+type searcher[R any] interface {
+	Search(q *content.Query) []R
+	Suggest(query string) string
+}
+
+// runSearch runs a search, falling back to a corrected query when nothing
+// matches. Besides the results, it returns why query is malformed, if it
+// is, and the corrected query whose results were returned instead, if any.
+func runSearch[R any](idx searcher[R], query string) (hits []R, queryErr, suggestion string) {
+	q, err := content.ParseQuery(query)
+	if err != nil {
+		return nil, err.Error(), ""
+	}
+	if hits = idx.Search(q); len(hits) > 0 {
+		return hits, "", ""
+	}
+
+	suggestion = idx.Suggest(query)
+	if suggestion == "" {
+		return nil, "", ""
+	}
+	sq, err := content.ParseQuery(suggestion)
+	if err != nil {
+		return nil, "", ""
+	}
+	if hits = idx.Search(sq); len(hits) == 0 {
+		return nil, "", ""
+	}
+	return hits, "", suggestion
+}
@@ -3,7 +3,10 @@ package handler
 import (
 	"bytes"
 	"log/slog"
+	"maps"
 	"net/http"
+	"net/url"
+	"slices"
 	"time"
 
 	"github.com/willfindlay/williamfindlaycom/internal/content"
@@ -12,6 +15,8 @@ import (
 type sitemapData struct {
 	SiteURL  string
 	Posts    []content.BlogPost
+	Series   []string // slugs
+	Tags     []string // path-escaped slugs
 	Projects []content.Project
 
 	BlogLastmod     time.Time
@@ -29,6 +34,10 @@ func (d *Deps) Sitemap() http.HandlerFunc {
 
 		if store != nil {
 			data.Posts = store.Posts
+			data.Series = slices.Sorted(maps.Keys(store.PostsBySeries))
+			for _, t := range slices.Sorted(maps.Keys(store.PostsByTag)) {
+				data.Tags = append(data.Tags, url.PathEscape(t))
+			}
 			data.Projects = store.Projects
 
 			if len(store.Posts) > 0 {
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/willfindlay/williamfindlaycom/internal/content"
+	"github.com/willfindlay/williamfindlaycom/internal/version"
+)
+
+type statusResponse struct {
+	OK      bool           `json:"ok"`
+	Build   buildStatus    `json:"build"`
+	Content *contentStatus `json:"content,omitempty"`
+	Sync    syncStatus     `json:"sync"`
+}
+
+type buildStatus struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+}
+
+type contentStatus struct {
+	Commit     string     `json:"commit,omitempty"`
+	CommitTime *time.Time `json:"commit_time,omitempty"`
+	Posts      int        `json:"posts"`
+	Projects   int        `json:"projects"`
+	Redirects  int        `json:"redirects"`
+
+	LoadErrors []loadErrorStatus `json:"load_errors,omitempty"`
+}
+
+type loadErrorStatus struct {
+	File  string `json:"file"`
+	Error string `json:"error,omitempty"` // left out of the public status
+}
+
+// syncStatus gives the stage the last sync failed at — fetch, verify or
+// load — rather than its error, which can name the repo, local paths or
+// signing keys. /admin/diagnostics has the details.
+type syncStatus struct {
+	LastAttempt *time.Time `json:"last_attempt,omitempty"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+// Status reports which content is being served and how recent syncs went.
+// It responds 503 when the last sync failed or skipped files that failed to
+// load, so uptime monitors notice a site that has stopped updating. Errors
+// are reported by stage and file; their messages are kept for the admin
+// pages.
+func (d *Deps) Status(sync func() content.SyncStatus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		st := sync()
+		resp := statusResponse{
+			OK: st.LastError == "",
+			Build: buildStatus{
+				Version:   version.Version,
+				Commit:    version.Commit,
+				BuildTime: version.BuildTime,
+			},
+			Sync: syncStatus{
+				LastAttempt: timeOrNil(st.LastAttempt),
+				LastSuccess: timeOrNil(st.LastSuccess),
+				LastError:   st.LastErrorStage,
+			},
+		}
+
+		if store := d.Store.Load(); store != nil {
+			resp.Content = newContentStatus(store)
+			for i := range resp.Content.LoadErrors {
+				resp.Content.LoadErrors[i].Error = ""
+			}
+			resp.OK = resp.OK && len(store.LoadErrors) == 0
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if !resp.OK {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			slog.Error("status encode error", "err", err)
+		}
+	}
+}
+
+func newContentStatus(cs *content.ContentStore) *contentStatus {
+	return &contentStatus{
+		Commit:     cs.Commit,
+		CommitTime: timeOrNil(cs.CommitTime),
+		Posts:      len(cs.Posts),
+		Projects:   len(cs.Projects),
+		Redirects:  len(cs.Redirects),
+		LoadErrors: loadErrorStatuses(cs.LoadErrors),
+	}
+}
+
+func loadErrorStatuses(errs []content.LoadError) []loadErrorStatus {
+	var out []loadErrorStatus
+	for _, e := range errs {
+		out = append(out, loadErrorStatus{File: e.File, Error: e.Err.Error()})
+	}
+	return out
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maxWebhookBody caps the size of webhook payloads we are willing to read.
+const maxWebhookBody = 5 << 20
+
+// ContentWebhook handles GitHub and Gitea push webhooks for the content
+// repo. Requests must be signed with secret; pushes to a ref for which
+// tracks returns true call trigger, anything else is acknowledged and
+// ignored.
+func ContentWebhook(secret string, tracks func(ref string) bool, trigger func()) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+		if err != nil {
+			http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		if !validWebhookSignature(secret, body, r.Header) {
+			slog.Warn("content webhook: bad signature", "remote", r.RemoteAddr)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		event := r.Header.Get("X-GitHub-Event")
+		if event == "" {
+			event = r.Header.Get("X-Gitea-Event")
+		}
+		if event != "push" {
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte("ignored: " + event + " event"))
+			return
+		}
+
+		// GitHub can be configured to send the payload form-encoded.
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+			form, err := url.ParseQuery(string(body))
+			if err != nil {
+				http.Error(w, "Bad Request", http.StatusBadRequest)
+				return
+			}
+			body = []byte(form.Get("payload"))
+		}
+
+		var push struct {
+			Ref string `json:"ref"`
+		}
+		if err := json.Unmarshal(body, &push); err != nil {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+		if !tracks(push.Ref) {
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte("ignored: push to " + push.Ref))
+			return
+		}
+
+		slog.Info("content webhook: sync triggered", "ref", push.Ref)
+		trigger()
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte("sync triggered"))
+	}
+}
+
+// validWebhookSignature checks the HMAC-SHA256 of body against the
+// signature header sent by GitHub (X-Hub-Signature-256, "sha256=" prefixed)
+// or Gitea (X-Gitea-Signature, bare hex).
+func validWebhookSignature(secret string, body []byte, h http.Header) bool {
+	sig := strings.TrimPrefix(h.Get("X-Hub-Signature-256"), "sha256=")
+	if sig == "" {
+		sig = h.Get("X-Gitea-Signature")
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil || len(got) == 0 {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
@@ -0,0 +1,280 @@
+// Package ogimage draws the social card images shown when a page is shared,
+// in pure Go so it needs nothing from the host. Text is set in DejaVu Sans,
+// the site's display face, loaded from the TrueType copies of the site's
+// fonts in static/fonts.
+package ogimage
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io/fs"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Width and Height are the size of a card, the 1.91:1 ratio social sites
+// expect.
+const (
+	Width  = 1200
+	Height = 630
+)
+
+// Version changes whenever cards are drawn differently, so callers can
+// tell cached cards are stale.
+const Version = "1"
+
+// Card is the text shown on a social card.
+type Card struct {
+	Kicker string // small line above the title, e.g. "Blog"
+	Title  string
+	Meta   string // line below the title, e.g. the date
+	Tags   []string
+	Site   string // shown next to the logo
+	Domain string // shown in the bottom corner
+}
+
+// Site colours, from static/css/main.css.
+var (
+	colorBg        = color.RGBA{0x0a, 0x0e, 0x17, 0xff}
+	colorText      = color.RGBA{0xe2, 0xe8, 0xf0, 0xff}
+	colorMuted     = color.RGBA{0x94, 0xa3, 0xb8, 0xff}
+	colorAccent    = color.RGBA{0x4f, 0xd1, 0xc5, 0xff}
+	colorAccentDim = color.RGBA{0x10, 0x2b, 0x32, 0xff} // accent at 15% over the background
+	colorSecondary = color.RGBA{0x80, 0x5a, 0xd5, 0xff}
+)
+
+const (
+	margin         = 80
+	kickerBaseline = 200
+	maxLines       = 3
+	maxTags        = 4
+	titleSize      = 68
+	minTitle       = 52
+	lineFactor     = 1.15
+)
+
+// Font files, relative to the site's assets. Browsers get the WOFF2
+// versions, which opentype can't parse.
+const (
+	regularFont = "static/fonts/DejaVuSans.ttf"
+	boldFont    = "static/fonts/DejaVuSans-Bold.ttf"
+)
+
+// Renderer draws cards in the site's fonts.
+type Renderer struct {
+	regular, bold *opentype.Font
+}
+
+// New loads the fonts cards are set in from the site's assets.
+func New(assets fs.FS) (*Renderer, error) {
+	regular, err := loadFont(assets, regularFont)
+	if err != nil {
+		return nil, err
+	}
+	bold, err := loadFont(assets, boldFont)
+	if err != nil {
+		return nil, err
+	}
+	return &Renderer{regular: regular, bold: bold}, nil
+}
+
+func loadFont(assets fs.FS, name string) (*opentype.Font, error) {
+	data, err := fs.ReadFile(assets, name)
+	if err != nil {
+		return nil, fmt.Errorf("reading font: %w", err)
+	}
+	f, err := opentype.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", name, err)
+	}
+	return f, nil
+}
+
+// Render draws c as a PNG image.
+func (r *Renderer) Render(c Card) ([]byte, error) {
+	regular, bold := r.regular, r.bold
+
+	img := image.NewRGBA(image.Rect(0, 0, Width, Height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(colorBg), image.Point{}, draw.Src)
+	drawAccentBar(img)
+
+	// Header: logo mark and site name.
+	mark, err := newFace(bold, 44)
+	if err != nil {
+		return nil, err
+	}
+	x := drawText(img, mark, colorAccent, margin, margin+44, "WF")
+	if c.Site != "" {
+		site, err := newFace(regular, 30)
+		if err != nil {
+			return nil, err
+		}
+		drawText(img, site, colorMuted, x+24, margin+40, c.Site)
+	}
+
+	// The title shrinks until it fits, then is cut off with an ellipsis.
+	size := float64(titleSize)
+	var title font.Face
+	var lines []string
+	for {
+		if title, err = newFace(bold, size); err != nil {
+			return nil, err
+		}
+		lines = wrap(title, c.Title, Width-2*margin)
+		if len(lines) <= maxLines || size <= minTitle {
+			break
+		}
+		size -= 8
+	}
+	if len(lines) > maxLines {
+		lines = lines[:maxLines]
+		lines[maxLines-1] = ellipsize(title, lines[maxLines-1]+" …", Width-2*margin)
+	}
+
+	if c.Kicker != "" {
+		kicker, err := newFace(bold, 26)
+		if err != nil {
+			return nil, err
+		}
+		drawText(img, kicker, colorAccent, margin, kickerBaseline, strings.ToUpper(c.Kicker))
+	}
+
+	lineHeight := int(size * lineFactor)
+	y := kickerBaseline + 20 + int(size)
+	for i, line := range lines {
+		if i > 0 {
+			y += lineHeight
+		}
+		drawText(img, title, colorText, margin, y, line)
+	}
+
+	if c.Meta != "" {
+		meta, err := newFace(regular, 30)
+		if err != nil {
+			return nil, err
+		}
+		drawText(img, meta, colorMuted, margin, y+56, c.Meta)
+	}
+
+	// Footer: tags on the left, the domain on the right.
+	small, err := newFace(regular, 26)
+	if err != nil {
+		return nil, err
+	}
+	footer := Height - margin
+	domainX := Width - margin
+	if c.Domain != "" {
+		domainX -= measure(small, c.Domain)
+		drawText(img, small, colorMuted, domainX, footer, c.Domain)
+	}
+	x = margin
+	for i, tag := range c.Tags {
+		if i == maxTags {
+			break
+		}
+		w := measure(small, tag) + 32
+		if x+w > domainX-32 {
+			break
+		}
+		fillRoundRect(img, image.Rect(x, footer-34, x+w, footer+12), 23, colorAccentDim)
+		drawText(img, small, colorAccent, x+16, footer, tag)
+		x += w + 16
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func newFace(f *opentype.Font, size float64) (font.Face, error) {
+	return opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+}
+
+// drawText draws s with its baseline at y and returns the x it ends at.
+func drawText(img draw.Image, face font.Face, c color.Color, x, y int, s string) int {
+	d := font.Drawer{Dst: img, Src: image.NewUniform(c), Face: face, Dot: fixed.P(x, y)}
+	d.DrawString(s)
+	return d.Dot.X.Ceil()
+}
+
+func measure(face font.Face, s string) int {
+	return font.MeasureString(face, s).Ceil()
+}
+
+// wrap breaks s into lines no wider than width, breaking between words.
+// A word wider than width gets a line to itself.
+func wrap(face font.Face, s string, width int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(s) {
+		next := word
+		if line != "" {
+			next = line + " " + word
+		}
+		if line != "" && measure(face, next) > width {
+			lines = append(lines, line)
+			next = word
+		}
+		line = next
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// ellipsize trims words from the end of s, which ends in an ellipsis,
+// until it fits in width.
+func ellipsize(face font.Face, s string, width int) string {
+	for measure(face, s) > width {
+		words := strings.Fields(strings.TrimSuffix(s, " …"))
+		if len(words) <= 1 {
+			break
+		}
+		s = strings.Join(words[:len(words)-1], " ") + " …"
+	}
+	return s
+}
+
+// drawAccentBar draws the accent-to-secondary gradient along the top edge,
+// as on the site's headings.
+func drawAccentBar(img *image.RGBA) {
+	for x := 0; x < Width; x++ {
+		t := float64(x) / float64(Width-1)
+		c := color.RGBA{
+			R: lerp(colorAccent.R, colorSecondary.R, t),
+			G: lerp(colorAccent.G, colorSecondary.G, t),
+			B: lerp(colorAccent.B, colorSecondary.B, t),
+			A: 0xff,
+		}
+		for y := 0; y < 8; y++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+}
+
+func lerp(a, b uint8, t float64) uint8 {
+	return uint8(float64(a) + (float64(b)-float64(a))*t + 0.5)
+}
+
+// fillRoundRect fills r with rounded corners of radius rad.
+func fillRoundRect(img *image.RGBA, r image.Rectangle, rad int, c color.RGBA) {
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			cx := min(max(x, r.Min.X+rad), r.Max.X-rad-1)
+			cy := min(max(y, r.Min.Y+rad), r.Max.Y-rad-1)
+			if dx, dy := x-cx, y-cy; dx*dx+dy*dy <= rad*rad {
+				img.SetRGBA(x, y, c)
+			}
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package ogimage
+
+import (
+	"bytes"
+	"image/png"
+	"os"
+	"strings"
+	"testing"
+)
+
+// newRenderer loads the fonts from the repo's static directory.
+func newRenderer(t *testing.T) *Renderer {
+	t.Helper()
+	r, err := New(os.DirFS("../.."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestRender(t *testing.T) {
+	r := newRenderer(t)
+	cards := []Card{
+		{Kicker: "Blog", Title: "Short", Meta: "January 2, 2024", Tags: []string{"go"}, Site: "Site", Domain: "example.com"},
+		{Title: strings.Repeat("A very long title that keeps going ", 10), Tags: strings.Fields("a b c d e f")},
+		{},
+	}
+	for _, c := range cards {
+		data, err := r.Render(c)
+		if err != nil {
+			t.Fatalf("Render(%+v): %v", c, err)
+		}
+		cfg, err := png.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("decoding card: %v", err)
+		}
+		if cfg.Width != Width || cfg.Height != Height {
+			t.Errorf("expected a %dx%d card, got %dx%d", Width, Height, cfg.Width, cfg.Height)
+		}
+	}
+}
+
+func TestWrap(t *testing.T) {
+	face, err := newFace(newRenderer(t).bold, titleSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	width := measure(face, "two words")
+	lines := wrap(face, "two words two words two", width)
+	if strings.Join(lines, "|") != "two words|two words|two" {
+		t.Errorf("unexpected lines %q", lines)
+	}
+
+	if got := ellipsize(face, "two words two words …", width); measure(face, got) > width || !strings.HasSuffix(got, " …") {
+		t.Errorf("expected an ellipsized line within %dpx, got %q", width, got)
+	}
+
+	// A word that can't fit still gets a line.
+	if lines := wrap(face, "supercalifragilistic", 10); len(lines) != 1 {
+		t.Errorf("expected one line, got %q", lines)
+	}
+}
+
+func TestNew_MissingFont(t *testing.T) {
+	if _, err := New(os.DirFS(t.TempDir())); err == nil {
+		t.Error("expected an error without the font files")
+	}
+}
@@ -6,11 +6,16 @@ import (
 	"io"
 	"io/fs"
 	"strings"
+	"sync"
 	texttemplate "text/template"
 	"time"
 )
 
 type Renderer struct {
+	fsys          fs.FS
+	cssBundlePath string
+
+	mu          sync.RWMutex
 	templates   map[string]*template.Template
 	feedTmpl    *texttemplate.Template
 	sitemapTmpl *texttemplate.Template
@@ -50,58 +55,77 @@ func xmlEscape(s string) string {
 }
 
 func New(fsys fs.FS, cssBundlePath string) (*Renderer, error) {
+	r := &Renderer{fsys: fsys, cssBundlePath: cssBundlePath}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload re-parses every template from the renderer's filesystem. If
+// parsing fails the previously loaded templates stay in use.
+func (r *Renderer) Reload() error {
 	fmap := template.FuncMap{}
 	for k, v := range funcMap {
 		fmap[k] = v
 	}
-	fmap["cssBundle"] = func() string { return cssBundlePath }
+	fmap["cssBundle"] = func() string { return r.cssBundlePath }
 
-	base, err := template.New("base").Funcs(fmap).ParseFS(fsys, "templates/base.html")
+	base, err := template.New("base").Funcs(fmap).ParseFS(r.fsys, "templates/base.html")
 	if err != nil {
-		return nil, fmt.Errorf("parsing base template: %w", err)
+		return fmt.Errorf("parsing base template: %w", err)
 	}
 
 	pages := []string{
 		"templates/home.html",
 		"templates/blog/list.html",
 		"templates/blog/post.html",
+		"templates/blog/series.html",
+		"templates/blog/tag.html",
 		"templates/projects/list.html",
 		"templates/projects/project.html",
 		"templates/resume.html",
+		"templates/search.html",
 		"templates/404.html",
+		"templates/admin/diagnostics.html",
 	}
 
-	r := &Renderer{templates: make(map[string]*template.Template)}
+	templates := make(map[string]*template.Template)
 
 	for _, page := range pages {
 		t, err := base.Clone()
 		if err != nil {
-			return nil, fmt.Errorf("cloning base for %s: %w", page, err)
+			return fmt.Errorf("cloning base for %s: %w", page, err)
 		}
-		t, err = t.ParseFS(fsys, page)
+		t, err = t.ParseFS(r.fsys, page)
 		if err != nil {
-			return nil, fmt.Errorf("parsing %s: %w", page, err)
+			return fmt.Errorf("parsing %s: %w", page, err)
 		}
-		r.templates[page] = t
+		templates[page] = t
 	}
 
-	feedTmpl, err := texttemplate.New("feed.xml").Funcs(feedFuncMap).ParseFS(fsys, "templates/feed.xml")
+	feedTmpl, err := texttemplate.New("feed.xml").Funcs(feedFuncMap).ParseFS(r.fsys, "templates/feed.xml")
 	if err != nil {
-		return nil, fmt.Errorf("parsing feed template: %w", err)
+		return fmt.Errorf("parsing feed template: %w", err)
 	}
-	r.feedTmpl = feedTmpl
 
-	sitemapTmpl, err := texttemplate.New("sitemap.xml").Funcs(feedFuncMap).ParseFS(fsys, "templates/sitemap.xml")
+	sitemapTmpl, err := texttemplate.New("sitemap.xml").Funcs(feedFuncMap).ParseFS(r.fsys, "templates/sitemap.xml")
 	if err != nil {
-		return nil, fmt.Errorf("parsing sitemap template: %w", err)
+		return fmt.Errorf("parsing sitemap template: %w", err)
 	}
-	r.sitemapTmpl = sitemapTmpl
 
-	return r, nil
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.templates = templates
+	r.feedTmpl = feedTmpl
+	r.sitemapTmpl = sitemapTmpl
+	return nil
 }
 
 func (r *Renderer) Render(w io.Writer, name string, data any) error {
+	r.mu.RLock()
 	t, ok := r.templates[name]
+	r.mu.RUnlock()
 	if !ok {
 		return fmt.Errorf("template %q not found", name)
 	}
@@ -109,9 +133,15 @@ func (r *Renderer) Render(w io.Writer, name string, data any) error {
 }
 
 func (r *Renderer) RenderFeed(w io.Writer, data any) error {
-	return r.feedTmpl.ExecuteTemplate(w, "feed", data)
+	r.mu.RLock()
+	t := r.feedTmpl
+	r.mu.RUnlock()
+	return t.ExecuteTemplate(w, "feed", data)
 }
 
 func (r *Renderer) RenderSitemap(w io.Writer, data any) error {
-	return r.sitemapTmpl.ExecuteTemplate(w, "sitemap", data)
+	r.mu.RLock()
+	t := r.sitemapTmpl
+	r.mu.RUnlock()
+	return t.ExecuteTemplate(w, "sitemap", data)
 }
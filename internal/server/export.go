@@ -0,0 +1,240 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/willfindlay/williamfindlaycom/internal/content"
+)
+
+// notFoundPath is requested during export to capture the 404 page. Static
+// hosts conventionally serve 404.html for missing paths.
+const notFoundPath = "/404.html"
+
+// Build syncs content and exports the site into outDir. Unlike serving,
+// which skips files that fail to load, it fails on them: a published build
+// would silently lack them until the next one.
+func (s *Server) Build(outDir string) error {
+	if err := s.loadContent(); err != nil {
+		return err
+	}
+	if cs := s.store.Load(); cs != nil && len(cs.LoadErrors) > 0 {
+		errs := make([]error, len(cs.LoadErrors))
+		for i, e := range cs.LoadErrors {
+			errs[i] = e
+		}
+		return fmt.Errorf("loading content: %w", errors.Join(errs...))
+	}
+
+	// Export into a fresh directory beside outDir and swap it in, so no
+	// files are left over from earlier builds and a failed build leaves
+	// the last one in place.
+	parent := filepath.Dir(outDir)
+	if err := os.MkdirAll(parent, 0o755); err != nil {
+		return fmt.Errorf("creating %s: %w", parent, err)
+	}
+	tmp, err := os.MkdirTemp(parent, "."+filepath.Base(outDir)+"-")
+	if err != nil {
+		return fmt.Errorf("creating build dir: %w", err)
+	}
+	defer os.RemoveAll(tmp) //nolint:errcheck // gone after a successful swap
+	if err := os.Chmod(tmp, 0o755); err != nil {
+		return err
+	}
+	if err := s.Export(tmp); err != nil {
+		return err
+	}
+	return replaceDir(outDir, tmp)
+}
+
+// replaceDir replaces dir with src. It refuses to remove a dir holding the
+// working directory, such as "." or "..", to keep a mistyped output dir
+// from deleting the project.
+func replaceDir(dir, src string) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(abs, wd); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("refusing to replace %s: it holds the working directory", dir)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("removing old %s: %w", dir, err)
+	}
+	if err := os.Rename(src, dir); err != nil {
+		return fmt.Errorf("moving build into %s: %w", dir, err)
+	}
+	return nil
+}
+
+// Export renders every page of the currently loaded site into outDir so it
+// can be deployed to a static host. Pages are produced by the live router,
+// so the output matches what the server itself would return.
+func (s *Server) Export(outDir string) error {
+	h := s.routes()
+
+	for _, p := range s.exportPaths() {
+		want := http.StatusOK
+		if p == notFoundPath {
+			want = http.StatusNotFound
+		}
+
+		req := httptest.NewRequest(http.MethodGet, p, nil)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != want {
+			return fmt.Errorf("exporting %s: got status %d", p, rec.Code)
+		}
+
+		// Static hosts decode the request path before looking up the file,
+		// so escaped paths, such as those of tags with spaces, are written
+		// unescaped.
+		file, err := url.PathUnescape(exportFile(p))
+		if err != nil || path.Clean(file) != file {
+			return fmt.Errorf("exporting %s: not a valid file path", p)
+		}
+		if err := writeExportFile(outDir, file, rec.Body.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	if err := s.exportStatic(outDir); err != nil {
+		return err
+	}
+
+	if err := s.exportRedirects(outDir); err != nil {
+		return err
+	}
+
+	slog.Info("site exported", "dir", outDir)
+	return nil
+}
+
+// exportPaths lists every GET route that makes sense on a static host.
+// Tag filtering on /blog happens client-side, so it needs no pages of its
+// own; each tag's /blog/tags page is exported instead.
+// /search is exported without results, so the link to it in the
+// navigation resolves, though searching needs the server.
+func (s *Server) exportPaths() []string {
+	paths := []string{
+		"/",
+		"/blog",
+		"/projects",
+		"/resume",
+		"/search",
+		"/feed.xml",
+		"/feed.json",
+		"/sitemap.xml",
+		"/robots.txt",
+		s.cssBundlePath,
+		notFoundPath,
+	}
+
+	if cs := s.store.Load(); cs != nil {
+		for _, p := range cs.Posts {
+			paths = append(paths, "/blog/"+p.Slug, "/og/blog/"+p.Slug+".png")
+			paths = append(paths, assetPaths("/blog/"+p.Slug, p.Assets)...)
+		}
+		for _, slug := range slices.Sorted(maps.Keys(cs.PostsBySeries)) {
+			paths = append(paths, "/blog/series/"+slug)
+		}
+		for _, tag := range slices.Sorted(maps.Keys(cs.PostsByTag)) {
+			paths = append(paths, "/blog/tags/"+url.PathEscape(tag))
+		}
+		for _, p := range cs.Projects {
+			paths = append(paths, "/projects/"+p.Slug, "/og/projects/"+p.Slug+".png")
+			paths = append(paths, assetPaths("/projects/"+p.Slug, p.Assets)...)
+		}
+	}
+
+	return paths
+}
+
+// assetPaths returns the escaped URL paths of a page bundle's assets and
+// their resized image variants, in a stable order.
+func assetPaths(prefix string, assets map[string]content.Asset) []string {
+	paths := make([]string, 0, len(assets))
+	add := func(name string) {
+		u := url.URL{Path: prefix + "/" + name}
+		paths = append(paths, u.EscapedPath())
+	}
+	for name := range assets {
+		add(name)
+	}
+	for _, name := range content.ImageVariants(assets) {
+		add(name)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// exportFile maps a URL path to the file that serves it: paths with an
+// extension are written as-is, others become a directory index.
+func exportFile(urlPath string) string {
+	if path.Ext(urlPath) != "" {
+		return urlPath
+	}
+	return path.Join(urlPath, "index.html")
+}
+
+func writeExportFile(outDir, urlPath string, data []byte) error {
+	dst := filepath.Join(outDir, filepath.FromSlash(strings.TrimPrefix(urlPath, "/")))
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return fmt.Errorf("creating directory for %s: %w", urlPath, err)
+	}
+	if err := os.WriteFile(dst, data, 0o644); err != nil {
+		return fmt.Errorf("writing %s: %w", urlPath, err)
+	}
+	return nil
+}
+
+func (s *Server) exportStatic(outDir string) error {
+	return fs.WalkDir(s.static, "static", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(s.static, p)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", p, err)
+		}
+		return writeExportFile(outDir, "/"+p, data)
+	})
+}
+
+// exportRedirects writes content redirects in the _redirects format
+// understood by Netlify and Cloudflare Pages.
+func (s *Server) exportRedirects(outDir string) error {
+	cs := s.store.Load()
+	if cs == nil || len(cs.Redirects) == 0 {
+		return nil
+	}
+
+	froms := make([]string, 0, len(cs.Redirects))
+	for from := range cs.Redirects {
+		froms = append(froms, from)
+	}
+	sort.Strings(froms)
+
+	var b strings.Builder
+	for _, from := range froms {
+		r := cs.Redirects[from]
+		fmt.Fprintf(&b, "%s %s %d\n", r.From, r.To, r.Code)
+	}
+	return writeExportFile(outDir, "/_redirects", []byte(b.String()))
+}
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/willfindlay/williamfindlaycom/internal/content"
+)
+
+func TestExport(t *testing.T) {
+	srv := newTestSite(t)
+	addBundles(t, srv)
+	out := t.TempDir()
+
+	if err := srv.Export(out); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	files := []string{
+		"index.html",
+		"blog/index.html",
+		"blog/test-post/index.html",
+		"blog/second-post/index.html",
+		"og/blog/test-post.png",
+		"blog/bundled/index.html",
+		"blog/bundled/diagram.svg",
+		"blog/bundled/data.csv",
+		"blog/bundled/photo.png",
+		"blog/bundled/photo.480w.png",
+		"blog/bundled/photo.960w.png",
+		"blog/series/bundles/index.html",
+		"blog/tags/go/index.html",
+		"blog/tags/open source/index.html",
+		"blog/tags/c#/index.html",
+		"blog/bundled/release notes.txt",
+		"projects/tool/shot.png",
+		"projects/index.html",
+		"resume/index.html",
+		"search/index.html",
+		"feed.xml",
+		"feed.json",
+		"sitemap.xml",
+		"robots.txt",
+		"404.html",
+		"static/css/main.css",
+		"static/js/navigation.js",
+		strings.TrimPrefix(srv.cssBundlePath, "/"),
+	}
+	for _, f := range files {
+		if _, err := os.Stat(filepath.Join(out, f)); err != nil {
+			t.Errorf("expected %s in export: %v", f, err)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(out, "blog/draft-post/index.html")); err == nil {
+		t.Error("draft post should not be exported")
+	}
+	if _, err := os.Stat(filepath.Join(out, "blog/wip")); err == nil {
+		t.Error("draft bundle should not be exported")
+	}
+
+	post, err := os.ReadFile(filepath.Join(out, "blog/test-post/index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(post), "Test Post") {
+		t.Error("expected exported post to contain its title")
+	}
+
+	notFound, err := os.ReadFile(filepath.Join(out, "404.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(notFound), "Not Found") {
+		t.Error("expected 404.html to contain the not found page")
+	}
+
+	redirects, err := os.ReadFile(filepath.Join(out, "_redirects"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(redirects); got != "/old-post /blog/test-post 301\n" {
+		t.Errorf("unexpected _redirects: %q", got)
+	}
+}
+
+func TestBuild_BrokenContent(t *testing.T) {
+	srv := newTestSite(t)
+	broken := filepath.Join(srv.syncCfg.Dir, "blog", "test-post.md")
+	if err := os.WriteFile(broken, []byte("---\ntitle: [broken\n---\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := t.TempDir()
+	err := srv.Build(out)
+	if err == nil || !strings.Contains(err.Error(), "blog/test-post.md") {
+		t.Fatalf("expected Build to fail on the broken post, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(out, "index.html")); err == nil {
+		t.Error("expected nothing to be exported")
+	}
+}
+
+func TestLoadContent_BrokenContent(t *testing.T) {
+	srv := newTestSite(t)
+	srv.store = content.NewAtomicStore()
+	srv.syncer = content.NewSyncer(srv.syncCfg, srv.store)
+	broken := filepath.Join(srv.syncCfg.Dir, "blog", "test-post.md")
+	if err := os.WriteFile(broken, []byte("---\ntitle: [broken\n---\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Serving carries on without the broken post rather than failing to
+	// start.
+	if err := srv.loadContent(); err != nil {
+		t.Fatalf("loadContent: %v", err)
+	}
+	cs := srv.store.Load()
+	if _, ok := cs.PostsBySlug["second-post"]; !ok {
+		t.Error("expected the other posts to be served")
+	}
+	if len(cs.LoadErrors) != 1 || cs.LoadErrors[0].File != "blog/test-post.md" {
+		t.Errorf("expected the broken post to be reported, got %v", cs.LoadErrors)
+	}
+}
+
+func TestBuild_ReplacesOutput(t *testing.T) {
+	srv := newTestSite(t)
+	out := filepath.Join(t.TempDir(), "dist")
+	stale := filepath.Join(out, "blog", "deleted-post", "index.html")
+	if err := os.MkdirAll(filepath.Dir(stale), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(stale, []byte("stale"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := srv.Build(out); err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("expected files of earlier builds to be removed, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(out, "blog", "test-post", "index.html")); err != nil {
+		t.Errorf("expected the post in the build: %v", err)
+	}
+	entries, err := os.ReadDir(filepath.Dir(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only the output dir to be left, got %v", entries)
+	}
+
+	t.Chdir(out)
+	if err := srv.Build("."); err == nil {
+		t.Error("expected Build to refuse to replace the working directory")
+	}
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/willfindlay/williamfindlaycom/internal/config"
+	"github.com/willfindlay/williamfindlaycom/internal/content"
+
+	williamfindlaycom "github.com/willfindlay/williamfindlaycom"
+)
+
+func newDevServer(t *testing.T) (*Server, string) {
+	t.Helper()
+
+	assets := t.TempDir()
+	if err := os.CopyFS(assets, williamfindlaycom.Embedded); err != nil {
+		t.Fatal(err)
+	}
+
+	srv, err := New(&config.Config{DevMode: true, AssetsDir: assets}, williamfindlaycom.Embedded)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	srv.store.Store(&content.ContentStore{})
+	return srv, assets
+}
+
+func TestLiveReload_Events(t *testing.T) {
+	srv, _ := newDevServer(t)
+	ts := httptest.NewServer(srv.routes())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/_dev/livereload")
+	if err != nil {
+		t.Fatalf("GET /_dev/livereload: %v", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected text/event-stream, got %q", ct)
+	}
+
+	// Wait for the handler to register before notifying.
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		srv.live.mu.Lock()
+		n := len(srv.live.clients)
+		srv.live.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("live reload client never registered")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	srv.live.notify()
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil {
+		t.Fatalf("reading event: %v", err)
+	}
+	if strings.TrimSpace(line) != "event: reload" {
+		t.Errorf("expected reload event, got %q", line)
+	}
+}
+
+func TestLiveReload_ReloadsAssetsFromDisk(t *testing.T) {
+	srv, assets := newDevServer(t)
+	ts := httptest.NewServer(srv.routes())
+	defer ts.Close()
+
+	get := func(path string) string {
+		t.Helper()
+		resp, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		defer resp.Body.Close() //nolint:errcheck
+		return readBody(t, resp)
+	}
+
+	body := get("/nonexistent")
+	if !strings.Contains(body, "/static/js/livereload.js") {
+		t.Error("expected live reload script in dev mode pages")
+	}
+	if !strings.Contains(body, devCSSBundlePath) {
+		t.Error("expected stable dev CSS bundle path")
+	}
+
+	tmpl := filepath.Join(assets, "templates", "404.html")
+	src, err := os.ReadFile(tmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited := strings.Replace(string(src), "Page not found.", "Nothing to see here.", 1)
+	if err := os.WriteFile(tmpl, []byte(edited), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	css := filepath.Join(assets, "static", "css", "main.css")
+	if err := os.WriteFile(css, []byte(".dev-marker {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.reloadAssets()
+
+	if body := get("/nonexistent"); !strings.Contains(body, "Nothing to see here.") {
+		t.Error("expected edited template after reload")
+	}
+	if body := get(devCSSBundlePath); !strings.Contains(body, ".dev-marker") {
+		t.Error("expected rebuilt CSS bundle after reload")
+	}
+}
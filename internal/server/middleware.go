@@ -1,8 +1,10 @@
 package server
 
 import (
+	"crypto/subtle"
 	"log/slog"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/willfindlay/williamfindlaycom/internal/content"
@@ -22,6 +24,36 @@ func logging(next http.Handler) http.Handler {
 	})
 }
 
+// requireToken rejects requests that don't carry token as a bearer token.
+func requireToken(token string, next http.Handler) http.Handler {
+	return checkToken(token, false, next)
+}
+
+// requireBrowserToken is requireToken that also accepts token as a basic
+// auth password, so a page works in a browser. Browsers resend cached basic
+// credentials on cross-site requests, so it must only guard safe methods.
+func requireBrowserToken(token string, next http.Handler) http.Handler {
+	return checkToken(token, true, next)
+}
+
+func checkToken(token string, basic bool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok && basic {
+			_, got, ok = r.BasicAuth()
+		}
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Add("WWW-Authenticate", "Bearer")
+			if basic {
+				w.Header().Add("WWW-Authenticate", `Basic realm="admin"`)
+			}
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 func securityHeaders(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("X-Content-Type-Options", "nosniff")
@@ -54,6 +86,15 @@ func cacheStatic(next http.Handler) http.Handler {
 	})
 }
 
+// noCache makes browsers revalidate every response. It runs inside
+// cacheStatic, so it overrides the caching headers set there.
+func noCache(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-cache")
+		next.ServeHTTP(w, r)
+	})
+}
+
 type statusWriter struct {
 	http.ResponseWriter
 	status int
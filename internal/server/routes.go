@@ -12,16 +12,35 @@ func (s *Server) routes() http.Handler {
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("GET /health", handler.Health())
+	mux.HandleFunc("GET /status", s.deps.Status(s.syncer.Status))
 	mux.HandleFunc("GET /{$}", s.deps.Home())
 	mux.HandleFunc("GET /blog", s.deps.BlogList())
 	mux.HandleFunc("GET /blog/{slug}", s.deps.BlogPost())
+	mux.HandleFunc("GET /blog/{slug}/{file...}", s.deps.BlogAsset())
+	mux.HandleFunc("GET /blog/series/{name}", s.deps.BlogSeries())
+	mux.HandleFunc("GET /blog/tags/{tag}", s.deps.BlogTag())
+	mux.HandleFunc("GET /preview/{token}/blog/{slug}", s.deps.BlogPreview())
+	mux.HandleFunc("GET /preview/{token}/blog/{slug}/{file...}", s.deps.BlogPreviewAsset())
 	mux.HandleFunc("GET /projects", s.deps.ProjectList())
 	mux.HandleFunc("GET /projects/{slug}", s.deps.ProjectDetail())
+	mux.HandleFunc("GET /projects/{slug}/{file...}", s.deps.ProjectAsset())
 	mux.HandleFunc("GET /resume", s.deps.Resume())
+	mux.HandleFunc("GET /search", s.deps.Search())
 	mux.HandleFunc("GET /feed.xml", s.deps.Feed())
 	mux.HandleFunc("GET /feed.json", s.deps.JSONFeed())
 	mux.HandleFunc("GET /sitemap.xml", s.deps.Sitemap())
 	mux.HandleFunc("GET /robots.txt", s.deps.Robots())
+	mux.HandleFunc("GET /og/blog/{file}", s.deps.BlogOGImage())
+	mux.HandleFunc("GET /og/projects/{file}", s.deps.ProjectOGImage())
+
+	if s.cfg.WebhookSecret != "" && s.cfg.ContentRepoURL != "" {
+		mux.HandleFunc("POST /hooks/content", handler.ContentWebhook(s.cfg.WebhookSecret, s.syncCfg.Tracks, s.syncer.Trigger))
+	}
+	if s.cfg.AdminToken != "" {
+		mux.Handle("GET /admin/diagnostics", requireBrowserToken(s.cfg.AdminToken, s.deps.AdminDiagnostics(s.syncer.Status)))
+		mux.Handle("GET /admin/snapshots", requireToken(s.cfg.AdminToken, s.deps.AdminSnapshots()))
+		mux.Handle("POST /admin/rollback", requireToken(s.cfg.AdminToken, s.deps.AdminRollback()))
+	}
 
 	mux.HandleFunc("GET "+s.cssBundlePath, s.serveCSSBundle)
 
@@ -29,7 +48,12 @@ func (s *Server) routes() http.Handler {
 	if err != nil {
 		panic(fmt.Sprintf("embedded static fs: %v", err))
 	}
-	mux.Handle("GET /static/", http.StripPrefix("/static/", cacheStatic(http.FileServerFS(staticFS))))
+	var static http.Handler = http.FileServerFS(staticFS)
+	if s.live != nil {
+		static = noCache(static)
+		mux.Handle("GET /_dev/livereload", s.live)
+	}
+	mux.Handle("GET /static/", http.StripPrefix("/static/", cacheStatic(static)))
 
 	// Catch-all for 404
 	mux.HandleFunc("GET /", s.deps.Home())
@@ -43,6 +67,13 @@ func (s *Server) routes() http.Handler {
 
 func (s *Server) serveCSSBundle(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/css; charset=utf-8")
-	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
-	w.Write(s.cssBundle) //nolint:errcheck
+	if s.live != nil {
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	}
+	s.cssMu.RLock()
+	bundle := s.cssBundle
+	s.cssMu.RUnlock()
+	w.Write(bundle) //nolint:errcheck
 }
@@ -1,10 +1,17 @@
 package server
 
 import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"image"
+	"image/png"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -13,13 +20,21 @@ import (
 	"github.com/willfindlay/williamfindlaycom/internal/config"
 	"github.com/willfindlay/williamfindlaycom/internal/content"
 	"github.com/willfindlay/williamfindlaycom/internal/handler"
+	"github.com/willfindlay/williamfindlaycom/internal/ogimage"
 	"github.com/willfindlay/williamfindlaycom/internal/render"
 
 	williamfindlaycom "github.com/willfindlay/williamfindlaycom"
 )
 
+const testPreviewSecret = "test-preview-secret"
+
 func newTestServer(t *testing.T) *httptest.Server {
 	t.Helper()
+	return httptest.NewServer(newTestSite(t).routes())
+}
+
+func newTestSite(t *testing.T) *Server {
+	t.Helper()
 
 	bundleBytes, bundlePath, err := buildCSSBundle(williamfindlaycom.Embedded)
 	if err != nil {
@@ -30,6 +45,10 @@ func newTestServer(t *testing.T) *httptest.Server {
 	if err != nil {
 		t.Fatalf("render.New: %v", err)
 	}
+	cards, err := ogimage.New(williamfindlaycom.Embedded)
+	if err != nil {
+		t.Fatalf("ogimage.New: %v", err)
+	}
 
 	// Create content dir with a blog post
 	contentDir := t.TempDir()
@@ -63,6 +82,20 @@ More content.
 		t.Fatal(err)
 	}
 
+	draft := `---
+title: Draft Post
+date: 2024-01-03
+description: Not ready yet
+draft: true
+tags: [test]
+---
+
+Work in progress.
+`
+	if err := os.WriteFile(filepath.Join(blogDir, "draft-post.md"), []byte(draft), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
 	redirectsYAML := `- from: /old-post
   to: /blog/test-post
   code: 301
@@ -79,23 +112,26 @@ More content.
 	store.Store(cs)
 
 	deps := &handler.Deps{
-		Store:     store,
-		Renderer:  renderer,
-		SiteTitle: "Test Site",
-		SiteURL:   "http://localhost",
-		Particles: config.ParticleConfig{},
+		Store:         store,
+		Renderer:      renderer,
+		Cards:         cards,
+		SiteTitle:     "Test Site",
+		SiteURL:       "http://localhost",
+		PreviewSecret: testPreviewSecret,
+		Particles:     config.ParticleConfig{},
 	}
 
-	srv := &Server{
+	syncCfg := content.SyncConfig{Dir: contentDir}
+	return &Server{
 		cfg:           &config.Config{},
 		static:        williamfindlaycom.Embedded,
 		store:         store,
 		deps:          deps,
+		syncCfg:       syncCfg,
+		syncer:        content.NewSyncer(syncCfg, store),
 		cssBundle:     bundleBytes,
 		cssBundlePath: bundlePath,
 	}
-
-	return httptest.NewServer(srv.routes())
 }
 
 func readBody(t *testing.T, resp *http.Response) string {
@@ -609,3 +645,914 @@ func TestRoutes_JSONFeed(t *testing.T) {
 		t.Error("expected non-empty content_html")
 	}
 }
+
+func TestRoutes_DraftHidden(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/blog/draft-post")
+	if err != nil {
+		t.Fatalf("GET /blog/draft-post: %v", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for draft post, got %d", resp.StatusCode)
+	}
+
+	for _, path := range []string{"/blog", "/feed.xml", "/feed.json", "/sitemap.xml"} {
+		resp, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		body := readBody(t, resp)
+		resp.Body.Close() //nolint:errcheck
+		if strings.Contains(body, "draft-post") {
+			t.Errorf("%s should not mention the draft post", path)
+		}
+	}
+}
+
+func TestRoutes_Preview(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	url := handler.PreviewURL(ts.URL, testPreviewSecret, "draft-post")
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("X-Robots-Tag"); !strings.Contains(got, "noindex") {
+		t.Errorf("expected noindex X-Robots-Tag, got %q", got)
+	}
+
+	body := readBody(t, resp)
+	if !strings.Contains(body, "Draft Post") {
+		t.Error("expected draft post title in preview")
+	}
+	if !strings.Contains(body, `<meta name="robots" content="noindex, nofollow">`) {
+		t.Error("expected robots noindex meta tag in preview")
+	}
+	if strings.Contains(body, `rel="canonical"`) || strings.Contains(body, "/blog/draft-post\"") {
+		t.Error("expected no canonical link to the unpublished post's URL")
+	}
+}
+
+func TestRoutes_PreviewBadToken(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	// A token for one post must not unlock another.
+	token := handler.PreviewToken(testPreviewSecret, "test-post")
+	resp, err := http.Get(ts.URL + "/preview/" + token + "/blog/draft-post")
+	if err != nil {
+		t.Fatalf("GET preview: %v", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for mismatched token, got %d", resp.StatusCode)
+	}
+}
+
+func TestRoutes_PreviewPublishedRedirects(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, err := client.Get(handler.PreviewURL(ts.URL, testPreviewSecret, "test-post"))
+	if err != nil {
+		t.Fatalf("GET preview: %v", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusFound {
+		t.Errorf("expected 302, got %d", resp.StatusCode)
+	}
+	if loc := resp.Header.Get("Location"); loc != "/blog/test-post" {
+		t.Errorf("expected Location /blog/test-post, got %q", loc)
+	}
+}
+
+func signWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestRoutes_ContentWebhook(t *testing.T) {
+	srv := newTestSite(t)
+	srv.cfg = &config.Config{
+		ContentRepoURL:    "https://example.com/content.git",
+		ContentRepoBranch: "main",
+		WebhookSecret:     "hook-secret",
+	}
+	srv.syncCfg = content.SyncConfig{RepoURL: srv.cfg.ContentRepoURL, Branch: "main"}
+	srv.syncer = content.NewSyncer(srv.syncCfg, srv.store)
+	ts := httptest.NewServer(srv.routes())
+	defer ts.Close()
+
+	post := func(event, signature string, body []byte) int {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, ts.URL+"/hooks/content", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-GitHub-Event", event)
+		if signature != "" {
+			req.Header.Set("X-Hub-Signature-256", signature)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("POST /hooks/content: %v", err)
+		}
+		resp.Body.Close() //nolint:errcheck
+		return resp.StatusCode
+	}
+
+	mainPush := []byte(`{"ref":"refs/heads/main"}`)
+	otherPush := []byte(`{"ref":"refs/heads/feature"}`)
+
+	tests := []struct {
+		name      string
+		event     string
+		signature string
+		body      []byte
+		want      int
+	}{
+		{"push to tracked branch", "push", signWebhook("hook-secret", mainPush), mainPush, http.StatusAccepted},
+		{"push to other branch", "push", signWebhook("hook-secret", otherPush), otherPush, http.StatusOK},
+		{"ping", "ping", signWebhook("hook-secret", []byte(`{}`)), []byte(`{}`), http.StatusOK},
+		{"missing signature", "push", "", mainPush, http.StatusUnauthorized},
+		{"wrong secret", "push", signWebhook("not-the-secret", mainPush), mainPush, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := post(tt.event, tt.signature, tt.body); got != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRoutes_ContentWebhookDisabled(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+"/hooks/content", "application/json", strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatalf("POST /hooks/content: %v", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode == http.StatusAccepted || resp.StatusCode == http.StatusOK {
+		t.Errorf("expected webhook to be disabled without a secret, got %d", resp.StatusCode)
+	}
+}
+
+func TestRoutes_Status(t *testing.T) {
+	srv := newTestSite(t)
+	ts := httptest.NewServer(srv.routes())
+	defer ts.Close()
+
+	getStatus := func() (int, map[string]any) {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/status")
+		if err != nil {
+			t.Fatalf("GET /status: %v", err)
+		}
+		defer resp.Body.Close() //nolint:errcheck
+		var body map[string]any
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("invalid JSON: %v", err)
+		}
+		return resp.StatusCode, body
+	}
+
+	if err := srv.syncer.Sync(); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	code, body := getStatus()
+	if code != http.StatusOK {
+		t.Errorf("expected 200 after a successful sync, got %d", code)
+	}
+	if body["ok"] != true {
+		t.Errorf("expected ok=true, got %v", body["ok"])
+	}
+	contentStatus, _ := body["content"].(map[string]any)
+	if contentStatus["posts"] != float64(2) || contentStatus["redirects"] != float64(1) {
+		t.Errorf("unexpected content counts: %v", contentStatus)
+	}
+	build, _ := body["build"].(map[string]any)
+	if build["version"] == nil {
+		t.Error("expected build version in status")
+	}
+	syncStatus, _ := body["sync"].(map[string]any)
+	if syncStatus["last_success"] == nil {
+		t.Error("expected last_success after a successful sync")
+	}
+
+	if err := os.RemoveAll(srv.syncCfg.Dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.syncer.Sync(); err == nil {
+		t.Fatal("expected sync of a missing content dir to fail")
+	}
+	code, body = getStatus()
+	if code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 after a failed sync, got %d", code)
+	}
+	syncStatus, _ = body["sync"].(map[string]any)
+	// Only the stage is published, not the error with its paths.
+	if syncStatus["last_error"] != content.StageLoad || syncStatus["last_success"] == nil {
+		t.Errorf("expected a load error and the earlier last_success, got %v", syncStatus)
+	}
+	// The previously loaded content is still served.
+	contentStatus, _ = body["content"].(map[string]any)
+	if contentStatus["posts"] != float64(2) {
+		t.Errorf("expected previous content to stay loaded, got %v", contentStatus)
+	}
+}
+
+func TestRoutes_AdminRollback(t *testing.T) {
+	srv := newTestSite(t)
+	srv.cfg = &config.Config{AdminToken: "admin-token"}
+
+	good := srv.store.Load()
+	good.Commit = "good"
+	bad := *good
+	bad.Commit = "bad"
+	bad.Posts = bad.Posts[:1]
+	srv.store.Store(&bad)
+
+	ts := httptest.NewServer(srv.routes())
+	defer ts.Close()
+
+	do := func(method, path, token string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(method, ts.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		return resp
+	}
+
+	for _, token := range []string{"", "wrong-token"} {
+		resp := do(http.MethodPost, "/admin/rollback", token)
+		resp.Body.Close() //nolint:errcheck
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("token %q: expected 401, got %d", token, resp.StatusCode)
+		}
+	}
+
+	// A browser would resend cached basic credentials on a cross-site form
+	// post, so they must not authorize a rollback.
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/admin/rollback", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("admin", "admin-token")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST /admin/rollback: %v", err)
+	}
+	resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("basic auth: expected 401, got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Values("WWW-Authenticate"); len(got) != 1 || got[0] != "Bearer" {
+		t.Errorf("expected only a bearer challenge, got %q", got)
+	}
+	if srv.store.Load().Commit != "bad" {
+		t.Fatal("unauthorized rollback changed the store")
+	}
+
+	resp = do(http.MethodGet, "/admin/snapshots", "admin-token")
+	var snapshots struct {
+		Snapshots []struct {
+			Commit string `json:"commit"`
+		} `json:"snapshots"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&snapshots); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	resp.Body.Close() //nolint:errcheck
+	if len(snapshots.Snapshots) != 2 || snapshots.Snapshots[0].Commit != "bad" {
+		t.Errorf("expected [bad good] snapshots, got %+v", snapshots.Snapshots)
+	}
+
+	resp = do(http.MethodPost, "/admin/rollback", "admin-token")
+	resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if got := srv.store.Load().Commit; got != "good" {
+		t.Errorf("expected rollback to the good commit, got %q", got)
+	}
+
+	resp = do(http.MethodPost, "/admin/rollback", "admin-token")
+	resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 with no older snapshot, got %d", resp.StatusCode)
+	}
+}
+
+func TestRoutes_AdminDisabled(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+"/admin/rollback", "", nil)
+	if err != nil {
+		t.Fatalf("POST /admin/rollback: %v", err)
+	}
+	resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode == http.StatusOK {
+		t.Error("expected admin routes to be unavailable without ADMIN_TOKEN")
+	}
+}
+
+func TestRoutes_LoadErrors(t *testing.T) {
+	srv := newTestSite(t)
+	srv.cfg = &config.Config{AdminToken: "admin-token"}
+
+	broken := filepath.Join(srv.syncCfg.Dir, "blog", "test-post.md")
+	if err := os.WriteFile(broken, []byte("---\ntitle: [broken\n---\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.syncer.Sync(); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+
+	ts := httptest.NewServer(srv.routes())
+	defer ts.Close()
+
+	// The broken post keeps being served from the previous load.
+	resp, err := http.Get(ts.URL + "/blog/test-post")
+	if err != nil {
+		t.Fatalf("GET /blog/test-post: %v", err)
+	}
+	resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected previous version of broken post, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(ts.URL + "/status")
+	if err != nil {
+		t.Fatalf("GET /status: %v", err)
+	}
+	body := readBody(t, resp)
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 with load errors, got %d", resp.StatusCode)
+	}
+	if !strings.Contains(body, `"file":"blog/test-post.md"`) {
+		t.Errorf("expected load error in status, got %s", body)
+	}
+	if strings.Contains(body, `"error"`) {
+		t.Errorf("expected the load error's message to be kept off the public status, got %s", body)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/admin/diagnostics", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("admin", "admin-token")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /admin/diagnostics: %v", err)
+	}
+	body = readBody(t, resp)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if !strings.Contains(body, "blog/test-post.md") || !strings.Contains(body, "invalid YAML") {
+		t.Error("expected the skipped file and why on the diagnostics page")
+	}
+	if !strings.Contains(body, `<meta name="robots" content="noindex, nofollow">`) {
+		t.Error("expected diagnostics page to be noindex")
+	}
+
+	resp, err = http.Get(ts.URL + "/admin/diagnostics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected 401 without credentials, got %d", resp.StatusCode)
+	}
+}
+
+// addBundles adds a published and a draft page bundle to the test site.
+func addBundles(t *testing.T, srv *Server) {
+	t.Helper()
+	files := map[string]string{
+		"blog/bundled/index.md":          "---\ntitle: Bundled\ndate: 2024-01-04\nseries: Bundles\ntags: [open source, c#]\n---\n\n![Diagram](diagram.svg)\n\n[Data](data.csv)\n\n[Notes](<release notes.txt>)\n\n![Photo](photo.png)\n",
+		"blog/bundled/diagram.svg":       `<svg xmlns="http://www.w3.org/2000/svg"/>`,
+		"blog/bundled/data.csv":          "a,b\n",
+		"blog/bundled/release notes.txt": "notes\n",
+		"blog/bundled/photo.png":         testPNG(t, 1000, 500),
+		"blog/wip/index.md":              "---\ntitle: WIP\ndate: 2024-01-05\ndraft: true\n---\n\n![Sketch](sketch.png)\n\nTo publish, move `sketch.png, /blog/wip/` out of drafts.\n",
+		"blog/wip/sketch.png":            "png",
+		"projects/tool/index.md":         "---\ntitle: Tool\ndate: 2024-01-01\n---\n\n![Shot](shot.png)\n",
+		"projects/tool/shot.png":         "png",
+	}
+	for name, data := range files {
+		path := filepath.Join(srv.syncCfg.Dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := srv.syncer.Sync(); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+}
+
+func testPNG(t *testing.T, width, height int) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestRoutes_BundleAssets(t *testing.T) {
+	srv := newTestSite(t)
+	addBundles(t, srv)
+	ts := httptest.NewServer(srv.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		path        string
+		status      int
+		contentType string
+	}{
+		{"/blog/bundled/diagram.svg", http.StatusOK, "image/svg+xml"},
+		{"/blog/bundled/data.csv", http.StatusOK, "text/csv"},
+		{"/blog/bundled/photo.480w.png", http.StatusOK, "image/png"},
+		{"/blog/bundled/photo.1600w.png", http.StatusNotFound, ""},
+		{"/blog/bundled/index.md", http.StatusNotFound, ""},
+		{"/blog/bundled/missing.png", http.StatusNotFound, ""},
+		{"/blog/wip/sketch.png", http.StatusNotFound, ""},
+		{"/projects/tool/shot.png", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		resp, err := http.Get(ts.URL + tt.path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", tt.path, err)
+		}
+		resp.Body.Close() //nolint:errcheck
+		if resp.StatusCode != tt.status {
+			t.Errorf("GET %s: expected %d, got %d", tt.path, tt.status, resp.StatusCode)
+		}
+		if ct := resp.Header.Get("Content-Type"); tt.contentType != "" && !strings.HasPrefix(ct, tt.contentType) {
+			t.Errorf("GET %s: expected content type %s, got %q", tt.path, tt.contentType, ct)
+		}
+	}
+
+	resp, err := http.Get(ts.URL + "/blog/bundled")
+	if err != nil {
+		t.Fatalf("GET /blog/bundled: %v", err)
+	}
+	body := readBody(t, resp)
+	if !strings.Contains(body, `src="/blog/bundled/diagram.svg"`) {
+		t.Error("expected the bundle's image to point at its asset URL")
+	}
+	if !strings.Contains(body, `srcset="/blog/bundled/photo.480w.png 480w, /blog/bundled/photo.960w.png 960w, /blog/bundled/photo.png 1000w"`) {
+		t.Error("expected a srcset of the photo's variants")
+	}
+
+	// A draft bundle's assets are reachable through its preview link.
+	preview := handler.PreviewURL(ts.URL, testPreviewSecret, "wip")
+	resp, err = http.Get(preview)
+	if err != nil {
+		t.Fatalf("GET %s: %v", preview, err)
+	}
+	body = readBody(t, resp)
+	token := handler.PreviewToken(testPreviewSecret, "wip")
+	if !strings.Contains(body, `src="/preview/`+token+`/blog/wip/sketch.png"`) {
+		t.Error("expected the draft's image to point at its preview asset URL")
+	}
+	if !strings.Contains(body, `<code>sketch.png, /blog/wip/</code>`) {
+		t.Error("expected the draft's text to be left alone")
+	}
+
+	resp, err = http.Get(preview + "/sketch.png")
+	if err != nil {
+		t.Fatalf("GET preview asset: %v", err)
+	}
+	if body := readBody(t, resp); resp.StatusCode != http.StatusOK || body != "png" {
+		t.Errorf("expected the preview asset, got %d %q", resp.StatusCode, body)
+	}
+}
+
+func TestRoutes_OGImages(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/og/blog/test-post.png", http.StatusOK},
+		{"/og/blog/draft-post.png", http.StatusNotFound},
+		{"/og/blog/nope.png", http.StatusNotFound},
+		{"/og/blog/test-post", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		resp, err := http.Get(ts.URL + tt.path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", tt.path, err)
+		}
+		body := readBody(t, resp)
+		if resp.StatusCode != tt.status {
+			t.Errorf("GET %s: expected %d, got %d", tt.path, tt.status, resp.StatusCode)
+			continue
+		}
+		if tt.status == http.StatusOK {
+			if ct := resp.Header.Get("Content-Type"); ct != "image/png" {
+				t.Errorf("GET %s: expected image/png, got %q", tt.path, ct)
+			}
+			if _, err := png.DecodeConfig(strings.NewReader(body)); err != nil {
+				t.Errorf("GET %s: invalid PNG: %v", tt.path, err)
+			}
+		}
+	}
+
+	resp, err := http.Get(ts.URL + "/blog/test-post")
+	if err != nil {
+		t.Fatalf("GET /blog/test-post: %v", err)
+	}
+	body := readBody(t, resp)
+	if !strings.Contains(body, `<meta property="og:image" content="http://localhost/og/blog/test-post.png">`) {
+		t.Error("expected the post's generated og:image")
+	}
+	if !strings.Contains(body, `<meta name="twitter:card" content="summary_large_image">`) {
+		t.Error("expected a large twitter card for the post")
+	}
+
+	// Previews keep the site-wide image, since their card isn't served.
+	resp, err = http.Get(handler.PreviewURL(ts.URL, testPreviewSecret, "draft-post"))
+	if err != nil {
+		t.Fatalf("GET preview: %v", err)
+	}
+	if body := readBody(t, resp); !strings.Contains(body, "/static/og-image.png") {
+		t.Error("expected the preview to use the default og:image")
+	}
+}
+
+func TestRoutes_TableOfContents(t *testing.T) {
+	srv := newTestSite(t)
+	post := "---\ntitle: Long Post\ndate: 2024-01-04\n---\n\n## First\n\n## Second\n\n### Detail\n"
+	if err := os.WriteFile(filepath.Join(srv.syncCfg.Dir, "blog", "long-post.md"), []byte(post), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.syncer.Sync(); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	ts := httptest.NewServer(srv.routes())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/blog/long-post")
+	if err != nil {
+		t.Fatalf("GET /blog/long-post: %v", err)
+	}
+	body := readBody(t, resp)
+	if !strings.Contains(body, `<nav class="toc"`) || !strings.Contains(body, `<a href="#detail">Detail</a>`) {
+		t.Error("expected a table of contents linking to the post's headings")
+	}
+
+	resp, err = http.Get(ts.URL + "/blog/test-post")
+	if err != nil {
+		t.Fatalf("GET /blog/test-post: %v", err)
+	}
+	if body := readBody(t, resp); strings.Contains(body, `<nav class="toc"`) {
+		t.Error("expected no table of contents on a post without headings")
+	}
+}
+
+func TestRoutes_Series(t *testing.T) {
+	srv := newTestSite(t)
+	posts := map[string]string{
+		"part-one.md": "---\ntitle: Part One\ndate: 2024-02-01\nseries:\n  name: Go Internals\n  order: 1\n---\n\nFirst.\n",
+		"part-two.md": "---\ntitle: Part Two\ndate: 2024-01-01\nseries:\n  name: Go Internals\n  order: 2\n---\n\nSecond.\n",
+	}
+	for name, post := range posts {
+		if err := os.WriteFile(filepath.Join(srv.syncCfg.Dir, "blog", name), []byte(post), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := srv.syncer.Sync(); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	ts := httptest.NewServer(srv.routes())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/blog/part-two")
+	if err != nil {
+		t.Fatalf("GET /blog/part-two: %v", err)
+	}
+	body := readBody(t, resp)
+	if !strings.Contains(body, `Part 2 of 2 in <a href="/blog/series/go-internals">Go Internals</a>`) {
+		t.Error("expected series navigation on a post in a series")
+	}
+	if !strings.Contains(body, `<a href="/blog/part-one">Part One</a>`) || !strings.Contains(body, `<span aria-current="page">Part Two</span>`) {
+		t.Error("expected links to every part with the current one marked")
+	}
+
+	resp, err = http.Get(ts.URL + "/blog/test-post")
+	if err != nil {
+		t.Fatalf("GET /blog/test-post: %v", err)
+	}
+	if body := readBody(t, resp); strings.Contains(body, `<aside class="series"`) {
+		t.Error("expected no series navigation on a post outside a series")
+	}
+
+	resp, err = http.Get(ts.URL + "/blog/series/go-internals")
+	if err != nil {
+		t.Fatalf("GET /blog/series/go-internals: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	body = readBody(t, resp)
+	one, two := strings.Index(body, `href="/blog/part-one"`), strings.Index(body, `href="/blog/part-two"`)
+	if one < 0 || two < 0 || one > two {
+		t.Error("expected the series page to list the parts in order")
+	}
+
+	resp, err = http.Get(ts.URL + "/blog/series/nonexistent")
+	if err != nil {
+		t.Fatalf("GET /blog/series/nonexistent: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown series, got %d", resp.StatusCode)
+	}
+}
+
+func TestRoutes_TagPages(t *testing.T) {
+	srv := newTestSite(t)
+	tags := "go:\n  name: Go\n  description: Posts about the Go language.\n  aliases: [golang]\n"
+	if err := os.WriteFile(filepath.Join(srv.syncCfg.Dir, "tags.yaml"), []byte(tags), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.syncer.Sync(); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	ts := httptest.NewServer(srv.routes())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/blog/tags/go")
+	if err != nil {
+		t.Fatalf("GET /blog/tags/go: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	body := readBody(t, resp)
+	for _, want := range []string{
+		`<h1 class="page-header__title">Go</h1>`,
+		"Posts about the Go language.",
+		`<link rel="canonical" href="http://localhost/blog/tags/go">`,
+		`href="/blog/test-post"`,
+		`"@type":"CollectionPage"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected tag page to contain %q", want)
+		}
+	}
+	if strings.Contains(body, `href="/blog/second-post"`) {
+		t.Error("expected the tag page to list only posts with the tag")
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	for path, want := range map[string]string{
+		"/blog/tags/golang": "/blog/tags/go",
+		"/blog?tag=GoLang":  "/blog?tag=go",
+	} {
+		resp, err := client.Get(ts.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusMovedPermanently || resp.Header.Get("Location") != want {
+			t.Errorf("GET %s: got %d to %q, want a redirect to %q", path, resp.StatusCode, resp.Header.Get("Location"), want)
+		}
+	}
+
+	resp, err = http.Get(ts.URL + "/blog/tags/nonexistent")
+	if err != nil {
+		t.Fatalf("GET /blog/tags/nonexistent: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for an unused tag, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(ts.URL + "/blog/test-post")
+	if err != nil {
+		t.Fatalf("GET /blog/test-post: %v", err)
+	}
+	if body := readBody(t, resp); !strings.Contains(body, `<a href="/blog/tags/go" class="tag">Go</a>`) {
+		t.Error("expected post tags to link to their tag pages by display name")
+	}
+}
+
+func TestRoutes_BlogSearchRanked(t *testing.T) {
+	srv := newTestSite(t)
+	post := "---\ntitle: Rust Ownership\ndate: 2023-06-01\ndescription: Borrowing explained\ntags: [rust]\n---\n\nLifetimes.\n"
+	if err := os.WriteFile(filepath.Join(srv.syncCfg.Dir, "blog", "ownership.md"), []byte(post), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.syncer.Sync(); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	ts := httptest.NewServer(srv.routes())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/blog?q=rust")
+	if err != nil {
+		t.Fatalf("GET /blog?q=rust: %v", err)
+	}
+	body := readBody(t, resp)
+	// The older post has rust in its title and tags, the newer only in its
+	// tags, so relevance puts it first despite the date order.
+	first, second := strings.Index(body, `href="/blog/ownership"`), strings.Index(body, `href="/blog/second-post"`)
+	if first < 0 || second < 0 || first > second {
+		t.Error("expected results ordered by relevance")
+	}
+	if !strings.Contains(body, `<div class="post-grid" data-filtered>`) {
+		t.Error("expected the grid to be marked as filtered by the server")
+	}
+}
+
+func TestRoutes_BlogSearchQueryLanguage(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/blog?q=" + url.QueryEscape("tag:go -rust"))
+	if err != nil {
+		t.Fatalf("GET /blog: %v", err)
+	}
+	body := readBody(t, resp)
+	if !strings.Contains(body, `href="/blog/test-post"`) || strings.Contains(body, `href="/blog/second-post"`) {
+		t.Error("expected tag: and exclusion to select only the test post")
+	}
+
+	resp, err = http.Get(ts.URL + "/blog?q=" + url.QueryEscape(`"unclosed`))
+	if err != nil {
+		t.Fatalf("GET /blog: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected 200 for a malformed query, got %d", resp.StatusCode)
+	}
+	body = readBody(t, resp)
+	if !strings.Contains(body, `class="search-error"`) || !strings.Contains(body, "missing its closing quote") {
+		t.Error("expected a friendly message for a malformed query")
+	}
+	if !strings.Contains(body, `id="empty-state" hidden`) {
+		t.Error("expected the empty state to give way to the error")
+	}
+}
+
+func TestRoutes_BlogSearchSuggestion(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/blog?q=sceond")
+	if err != nil {
+		t.Fatalf("GET /blog?q=sceond: %v", err)
+	}
+	body := readBody(t, resp)
+	if !strings.Contains(body, `Did you mean <a href="/blog?q=second">second</a>?`) {
+		t.Error("expected a did you mean suggestion")
+	}
+	if !strings.Contains(body, `href="/blog/second-post"`) {
+		t.Error("expected the suggestion's results to be shown")
+	}
+
+	resp, err = http.Get(ts.URL + "/blog?q=xyzzy")
+	if err != nil {
+		t.Fatalf("GET /blog?q=xyzzy: %v", err)
+	}
+	if body := readBody(t, resp); strings.Contains(body, "Did you mean") {
+		t.Error("expected no suggestion without a close word")
+	}
+}
+
+func TestRoutes_BlogSearchSnippets(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/blog?q=more")
+	if err != nil {
+		t.Fatalf("GET /blog?q=more: %v", err)
+	}
+	body := readBody(t, resp)
+	if !strings.Contains(body, `<p class="card__snippet"><mark>More</mark> content.</p>`) {
+		t.Error("expected a highlighted snippet for a body match")
+	}
+	if strings.Contains(body, `<p class="card__description">Another test post`) {
+		t.Error("expected the snippet in place of the description")
+	}
+}
+
+func TestRoutes_SiteSearch(t *testing.T) {
+	srv := newTestSite(t)
+	writeFile := func(name, data string) {
+		t.Helper()
+		path := filepath.Join(srv.syncCfg.Dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile("projects/sandbox.md", "---\ntitle: Sandbox\ndate: 2023-01-01\ndescription: A Rust sandbox\ntags: [rust]\n---\n\nConfines processes.\n")
+	writeFile("resume/resume.yaml", "name: Me\nexperience:\n  - title: Engineer\n    organization: Acme\n    location: Toronto\n    start: {year: 2022}\n    bullets:\n      - Wrote Rust services\n")
+	if err := srv.syncer.Sync(); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	ts := httptest.NewServer(srv.routes())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/search?q=rust")
+	if err != nil {
+		t.Fatalf("GET /search: %v", err)
+	}
+	body := readBody(t, resp)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	posts := strings.Index(body, ">Blog posts <")
+	projects := strings.Index(body, ">Projects <")
+	resume := strings.Index(body, ">Résumé <")
+	if posts < 0 || projects < 0 || resume < 0 || !(posts < projects && projects < resume) {
+		t.Errorf("expected posts, projects and résumé groups in order, got %d, %d, %d", posts, projects, resume)
+	}
+	for _, want := range []string{
+		`href="/blog/second-post"`,
+		`href="/projects/sandbox"`,
+		`href="/resume#experience-engineer-acme"`,
+		`Wrote <mark>Rust</mark> services`,
+		`<meta name="robots" content="noindex, nofollow">`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %s in search results", want)
+		}
+	}
+
+	resp, err = http.Get(ts.URL + "/resume")
+	if err != nil {
+		t.Fatalf("GET /resume: %v", err)
+	}
+	if body := readBody(t, resp); !strings.Contains(body, `id="experience-engineer-acme"`) {
+		t.Error("expected the résumé entry's anchor on /resume")
+	}
+
+	resp, err = http.Get(ts.URL + "/search?q=sandbx")
+	if err != nil {
+		t.Fatalf("GET /search: %v", err)
+	}
+	if body := readBody(t, resp); !strings.Contains(body, `Did you mean <a href="/search?q=sandbox">`) || !strings.Contains(body, `href="/projects/sandbox"`) {
+		t.Error("expected results for a corrected query")
+	}
+
+	resp, err = http.Get(ts.URL + "/search?q=%22oops")
+	if err != nil {
+		t.Fatalf("GET /search: %v", err)
+	}
+	if body := readBody(t, resp); !strings.Contains(body, `class="search-error"`) {
+		t.Error("expected a malformed query to be explained")
+	}
+
+	resp, err = http.Get(ts.URL + "/search")
+	if err != nil {
+		t.Fatalf("GET /search: %v", err)
+	}
+	if body := readBody(t, resp); strings.Contains(body, "noindex") || strings.Contains(body, "Nothing matching") {
+		t.Error("expected a plain, indexable search page without a query")
+	}
+}
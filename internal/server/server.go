@@ -9,51 +9,122 @@ import (
 	"io/fs"
 	"log/slog"
 	"net/http"
+	"os"
 	"os/signal"
+	"path/filepath"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/willfindlay/williamfindlaycom/internal/config"
 	"github.com/willfindlay/williamfindlaycom/internal/content"
 	"github.com/willfindlay/williamfindlaycom/internal/handler"
+	"github.com/willfindlay/williamfindlaycom/internal/ogimage"
 	"github.com/willfindlay/williamfindlaycom/internal/render"
+	"github.com/willfindlay/williamfindlaycom/internal/watch"
 )
 
+// devCSSBundlePath is used instead of a content-hashed path in dev mode, so
+// the bundle can be rebuilt without re-rendering the path into templates.
+const devCSSBundlePath = "/static/css/bundle.dev.css"
+
 type Server struct {
 	cfg           *config.Config
 	static        fs.FS
 	store         *content.AtomicStore
 	deps          *handler.Deps
+	live          *liveReload // nil outside dev mode
+	syncCfg       content.SyncConfig
+	syncer        *content.Syncer
+	cssMu         sync.RWMutex
 	cssBundle     []byte
 	cssBundlePath string
 }
 
 func New(cfg *config.Config, embedded fs.FS) (*Server, error) {
-	bundleBytes, bundlePath, err := buildCSSBundle(embedded)
+	// In dev mode templates and static files come from disk so they can be
+	// edited without rebuilding the binary.
+	assets := embedded
+	if cfg.DevMode {
+		assets = os.DirFS(cfg.AssetsDir)
+	}
+
+	bundleBytes, bundlePath, err := buildCSSBundle(assets)
 	if err != nil {
 		return nil, fmt.Errorf("building CSS bundle: %w", err)
 	}
+	if cfg.DevMode {
+		bundlePath = devCSSBundlePath
+	}
 
-	renderer, err := render.New(embedded, bundlePath)
+	renderer, err := render.New(assets, bundlePath)
 	if err != nil {
 		return nil, fmt.Errorf("initializing renderer: %w", err)
 	}
 
+	cards, err := ogimage.New(assets)
+	if err != nil {
+		return nil, fmt.Errorf("loading card fonts: %w", err)
+	}
+
 	store := content.NewAtomicStore()
+	store.SetHistoryLimit(cfg.ContentHistory)
 	deps := &handler.Deps{
-		Store:     store,
-		Renderer:  renderer,
-		SiteTitle: cfg.SiteTitle,
-		SiteURL:   cfg.SiteURL,
-		Particles: cfg.Particles,
-		Giscus:    cfg.Giscus,
+		Store:         store,
+		Renderer:      renderer,
+		Images:        content.NewImageCache(cfg.ImageCacheDir),
+		Cards:         cards,
+		SiteTitle:     cfg.SiteTitle,
+		SiteURL:       cfg.SiteURL,
+		PreviewSecret: cfg.PreviewSecret,
+		LiveReload:    cfg.DevMode,
+		Particles:     cfg.Particles,
+		Giscus:        cfg.Giscus,
+	}
+
+	syncCfg := content.SyncConfig{
+		RepoURL:   cfg.ContentRepoURL,
+		Branch:    cfg.ContentRepoBranch,
+		Ref:       cfg.ContentRepoRef,
+		Dir:       cfg.ContentDir,
+		Interval:  cfg.SyncInterval,
+		AuthToken: cfg.GitAuthToken,
+
+		SSHKey:                []byte(cfg.GitSSHKey),
+		SSHKeyPassphrase:      cfg.GitSSHPassphrase,
+		KnownHostsFile:        cfg.GitKnownHosts,
+		InsecureIgnoreHostKey: cfg.GitSSHInsecure,
+	}
+
+	if cfg.SigningKeysFile != "" {
+		data, err := os.ReadFile(cfg.SigningKeysFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading signing keys: %w", err)
+		}
+		syncCfg.TrustedKeys, err = content.ParseTrustedKeys(data)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", cfg.SigningKeysFile, err)
+		}
+	}
+
+	if cfg.GitSSHKey != "" && cfg.GitKnownHosts == "" && cfg.GitSSHInsecure {
+		slog.Warn("GIT_SSH_INSECURE is set; content repo host key will not be verified")
+	}
+
+	var live *liveReload
+	if cfg.DevMode {
+		live = newLiveReload()
+		syncCfg.OnReload = func(*content.ContentStore) { live.notify() }
 	}
 
 	return &Server{
 		cfg:           cfg,
-		static:        embedded,
+		static:        assets,
 		store:         store,
 		deps:          deps,
+		live:          live,
+		syncCfg:       syncCfg,
+		syncer:        content.NewSyncer(syncCfg, store),
 		cssBundle:     bundleBytes,
 		cssBundlePath: bundlePath,
 	}, nil
@@ -86,31 +157,55 @@ func buildCSSBundle(fsys fs.FS) ([]byte, string, error) {
 	return buf.Bytes(), path, nil
 }
 
-func (s *Server) Run() error {
-	syncCfg := content.SyncConfig{
-		RepoURL:   s.cfg.ContentRepoURL,
-		Branch:    s.cfg.ContentRepoBranch,
-		Dir:       s.cfg.ContentDir,
-		Interval:  s.cfg.SyncInterval,
-		AuthToken: s.cfg.GitAuthToken,
+func (s *Server) loadContent() error {
+	if s.syncCfg.RepoURL == "" {
+		slog.Info("serving local content dir", "dir", s.syncCfg.Dir)
 	}
-
-	// Initial content load
-	if err := content.CloneOrPull(syncCfg); err != nil {
+	if err := s.syncer.Sync(); err != nil {
 		return fmt.Errorf("initial content sync: %w", err)
 	}
+	return nil
+}
 
-	cs, err := content.LoadFromDir(s.cfg.ContentDir)
+// reloadAssets rebuilds the CSS bundle and re-parses templates from disk,
+// then tells connected browsers to refresh. On error the previous assets
+// stay in use.
+func (s *Server) reloadAssets() {
+	bundle, _, err := buildCSSBundle(s.static)
 	if err != nil {
-		return fmt.Errorf("initial content load: %w", err)
+		slog.Error("rebuilding CSS bundle", "err", err)
+		return
+	}
+	if err := s.deps.Renderer.Reload(); err != nil {
+		slog.Error("reloading templates", "err", err)
+		return
+	}
+
+	s.cssMu.Lock()
+	s.cssBundle = bundle
+	s.cssMu.Unlock()
+
+	slog.Info("assets reloaded")
+	s.live.notify()
+}
+
+func (s *Server) Run() error {
+	if err := s.loadContent(); err != nil {
+		return err
 	}
-	s.store.Store(cs)
-	slog.Info("content loaded", "posts", len(cs.Posts), "projects", len(cs.Projects), "redirects", len(cs.Redirects))
 
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
-	go content.StartBackgroundSync(ctx, syncCfg, s.store)
+	if s.live != nil {
+		go watch.Poll(ctx, 250*time.Millisecond, s.reloadAssets,
+			filepath.Join(s.cfg.AssetsDir, "templates"),
+			filepath.Join(s.cfg.AssetsDir, "static"),
+		)
+		slog.Info("dev mode: watching assets", "dir", s.cfg.AssetsDir)
+	}
+
+	go s.syncer.Run(ctx)
 
 	srv := &http.Server{
 		Addr:         fmt.Sprintf(":%s", s.cfg.Port),
@@ -0,0 +1,70 @@
+// Package watch detects changes to files on disk by polling. Polling needs
+// no platform-specific notification APIs, so it behaves the same everywhere,
+// including on bind mounts inside containers.
+package watch
+
+import (
+	"context"
+	"io/fs"
+	"maps"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type fileState struct {
+	size    int64
+	modTime int64
+}
+
+// Poll calls onChange whenever a file under any of roots is added, removed
+// or modified, checking every interval until ctx is done. Hidden files and
+// directories, such as .git or editor swap files, are ignored.
+func Poll(ctx context.Context, interval time.Duration, onChange func(), roots ...string) {
+	prev := snapshot(roots)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cur := snapshot(roots)
+			if !maps.Equal(prev, cur) {
+				prev = cur
+				onChange()
+			}
+		}
+	}
+}
+
+func snapshot(roots []string) map[string]fileState {
+	files := make(map[string]fileState)
+	for _, root := range roots {
+		//nolint:errcheck // the walk func never returns an error; unreadable paths are skipped.
+		filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				// Removed mid-walk or unreadable; the next poll will catch up.
+				return nil
+			}
+			if path != root && strings.HasPrefix(d.Name(), ".") {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			files[path] = fileState{size: info.Size(), modTime: info.ModTime().UnixNano()}
+			return nil
+		})
+	}
+	return files
+}
@@ -0,0 +1,75 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPoll(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.md"), []byte("one"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := make(chan struct{}, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go Poll(ctx, 10*time.Millisecond, func() { changes <- struct{}{} }, dir)
+
+	expectChange := func(what string) {
+		t.Helper()
+		select {
+		case <-changes:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no change reported after %s", what)
+		}
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if err := os.WriteFile(filepath.Join(dir, "a.md"), []byte("changed"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	expectChange("modifying a file")
+
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "b.md"), []byte("new"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	expectChange("adding a nested file")
+
+	if err := os.Remove(filepath.Join(dir, "a.md")); err != nil {
+		t.Fatal(err)
+	}
+	expectChange("removing a file")
+}
+
+func TestPoll_IgnoresHidden(t *testing.T) {
+	dir := t.TempDir()
+
+	changes := make(chan struct{}, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go Poll(ctx, 10*time.Millisecond, func() { changes <- struct{}{} }, dir)
+
+	time.Sleep(30 * time.Millisecond)
+	if err := os.MkdirAll(filepath.Join(dir, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".git", "HEAD"), []byte("ref"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".post.md.swp"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-changes:
+		t.Error("changes to hidden files should be ignored")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
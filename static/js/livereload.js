@@ -0,0 +1,14 @@
+// Dev mode only: reload the page when the server reports a template, CSS or
+// content change, or when it comes back after a restart.
+(() => {
+  const source = new EventSource("/_dev/livereload");
+  let lost = false;
+
+  source.addEventListener("reload", () => location.reload());
+  source.addEventListener("error", () => {
+    lost = true;
+  });
+  source.addEventListener("open", () => {
+    if (lost) location.reload();
+  });
+})();
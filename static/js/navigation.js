@@ -231,6 +231,12 @@
     }
   }
 
+  // Queries using the search syntax (phrases, exclusions, OR and
+  // qualifiers) are only understood by the server.
+  function isAdvancedQuery(query) {
+    return /[":]|(^|\s)-\S|\sOR\s/.test(query);
+  }
+
   function filterBlogPosts(posts, query) {
     const terms = query.toLowerCase().split(/\s+/).filter(Boolean);
     if (terms.length === 0) return posts;
@@ -251,6 +257,18 @@
     for (const pill of document.querySelectorAll("[data-tag]")) {
       pill.classList.toggle("tag--active", activeTags.has(pill.dataset.tag));
     }
+
+    // Static exports ignore the query string, so filter client-side too,
+    // unless the server already filtered and ranked the posts.
+    const searchInput = document.querySelector('.search-bar input[name="q"]');
+    if (searchInput && !searchInput.value && params.get("q")) {
+      searchInput.value = params.get("q");
+    }
+    const postGrid = document.querySelector(".post-grid");
+    if (postGrid && postGrid.hasAttribute("data-filtered")) return;
+    if (activeTags.size > 0 || params.get("q")) {
+      applyFilters();
+    }
   }
 
   function toggleTag(tag) {
@@ -275,7 +293,8 @@
     const query = searchInput ? searchInput.value.trim() : "";
 
     // Apply text search then tag filter (AND logic)
-    let filtered = query ? filterBlogPosts(posts, query) : posts;
+    let filtered =
+      query && !isAdvancedQuery(query) ? filterBlogPosts(posts, query) : posts;
 
     if (activeTags.size > 0) {
       filtered = filtered.filter((p) => {
@@ -326,20 +345,23 @@
   document.addEventListener("input", (e) => {
     if (
       e.target.matches('.search-bar input[name="q"]') &&
-      document.getElementById("blog-posts-data")
+      document.getElementById("blog-posts-data") &&
+      !isAdvancedQuery(e.target.value)
     ) {
       applyFilters();
     }
   });
 
-  // Prevent form submit from doing a full page reload when JS is active
+  // Typing filters instantly; submitting fetches the server's ranked
+  // results without a full page reload.
   document.addEventListener("submit", (e) => {
     if (
       e.target.matches(".search-bar") &&
       document.getElementById("blog-posts-data")
     ) {
       e.preventDefault();
-      applyFilters();
+      applyFilters(); // also puts the query in the URL
+      navigate(location.href, false);
     }
   });
 